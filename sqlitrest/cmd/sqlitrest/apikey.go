@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/cl-ment/sqlitrest/pkg/config"
+	"github.com/cl-ment/sqlitrest/pkg/db"
+)
+
+const apiKeyUsage = `Usage:
+  sqlitrest apikey create -name <name> -role <role> [-tenant <id>] [-permissions a,b] [-expires 720h]
+  sqlitrest apikey list
+  sqlitrest apikey revoke -id <id> | -name <name>`
+
+// runAPIKeyCommand exécute les sous-commandes "apikey create|list|revoke"
+func runAPIKeyCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand\n%s", apiKeyUsage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	dbManager, err := db.NewManager(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create DB manager: %w", err)
+	}
+	defer dbManager.Close()
+
+	database, err := dbManager.GetDB("main")
+	if err != nil {
+		return err
+	}
+
+	apiKeys, err := auth.NewAPIKeyManager(database.Writer)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		return createAPIKey(apiKeys, args[1:])
+	case "list":
+		return listAPIKeys(apiKeys)
+	case "revoke":
+		return revokeAPIKey(apiKeys, args[1:])
+	}
+
+	return fmt.Errorf("unknown subcommand %q\n%s", args[0], apiKeyUsage)
+}
+
+func createAPIKey(apiKeys *auth.APIKeyManager, args []string) error {
+	flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+	name := flags.String("name", "", "descriptive name of the key")
+	role := flags.String("role", "", "role granted to the key")
+	tenant := flags.String("tenant", "", "tenant ID")
+	permissions := flags.String("permissions", "", "comma-separated permissions")
+	expires := flags.Duration("expires", 0, "validity duration (e.g. 720h), none by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var perms []string
+	for _, p := range strings.Split(*permissions, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			perms = append(perms, p)
+		}
+	}
+
+	var expiresAt *time.Time
+	if *expires > 0 {
+		t := time.Now().Add(*expires)
+		expiresAt = &t
+	}
+
+	key, apiKey, err := apiKeys.Create(*name, *role, *tenant, perms, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("API key %d (%s) created for role %s\n", apiKey.ID, apiKey.Name, apiKey.Role)
+	fmt.Printf("Key: %s\n", key)
+	fmt.Println("Store it now: it cannot be shown again.")
+	return nil
+}
+
+// revokeAPIKey révoque une clé désignée explicitement par -id ou par -name
+func revokeAPIKey(apiKeys *auth.APIKeyManager, args []string) error {
+	flags := flag.NewFlagSet("apikey revoke", flag.ContinueOnError)
+	id := flags.Int64("id", 0, "id of the key to revoke")
+	name := flags.String("name", "", "name of the key to revoke (must match a single active key)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case flags.NArg() > 0 || (*id != 0) == (*name != ""):
+		return fmt.Errorf("revoke expects either -id or -name\n%s", apiKeyUsage)
+	case *id != 0:
+		if err := apiKeys.RevokeByID(*id); err != nil {
+			return err
+		}
+		fmt.Printf("API key %d revoked\n", *id)
+	default:
+		if err := apiKeys.RevokeByName(*name); err != nil {
+			return err
+		}
+		fmt.Printf("API key %s revoked\n", *name)
+	}
+	return nil
+}
+
+func listAPIKeys(apiKeys *auth.APIKeyManager) error {
+	keys, err := apiKeys.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tROLE\tTENANT\tPERMISSIONS\tEXPIRES\tLAST USED\tSTATUS")
+	for _, k := range keys {
+		status := "active"
+		if k.Revoked {
+			status = "revoked"
+		} else if k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt) {
+			status = "expired"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Name, k.Prefix, k.Role, k.TenantID, strings.Join(k.Permissions, ","),
+			formatOptionalTime(k.ExpiresAt), formatOptionalTime(k.LastUsedAt), status)
+	}
+	return w.Flush()
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
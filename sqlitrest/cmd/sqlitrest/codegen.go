@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cl-ment/sqlitrest/pkg/codegen"
+	"github.com/cl-ment/sqlitrest/pkg/config"
+	"github.com/cl-ment/sqlitrest/pkg/db"
+	"github.com/cl-ment/sqlitrest/pkg/engine"
+	"github.com/cl-ment/sqlitrest/pkg/openapi"
+)
+
+const codegenUsage = `Usage:
+  sqlitrest codegen -lang ts|go [-db main] [-out file] [-package client]`
+
+// runCodegenCommand génère un client typé depuis le schéma courant d'une base
+func runCodegenCommand(args []string) error {
+	flags := flag.NewFlagSet("codegen", flag.ContinueOnError)
+	lang := flags.String("lang", "", "target language: ts or go")
+	dbName := flags.String("db", "main", "database to describe")
+	out := flags.String("out", "", "output file (default: stdout)")
+	pkg := flags.String("package", "client", "Go package name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *lang == "" {
+		return fmt.Errorf("missing -lang\n%s", codegenUsage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	dbManager, err := db.NewManager(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create DB manager: %w", err)
+	}
+	defer dbManager.Close()
+
+	database, err := dbManager.GetDB(*dbName)
+	if err != nil {
+		return err
+	}
+
+	// Document complet (sans contrôle d'accès) : le client décrit tout le schéma
+	gen := openapi.NewOpenAPIGenerator(*dbName, engine.NewSchemaCache(database.Reader(), time.Minute))
+	gen.SetReadOnly(database.Writer == nil)
+	doc, err := gen.Generate(nil)
+	if err != nil {
+		return err
+	}
+
+	code, err := codegen.Generate(*lang, doc, codegen.Options{Database: *dbName, Package: *pkg})
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	if err := os.WriteFile(*out, code, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *out, err)
+	}
+	fmt.Fprintf(os.Stderr, "Generated %s client for database %s in %s\n", *lang, *dbName, *out)
+	return nil
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/cl-ment/sqlitrest/internal/server"
 	"github.com/cl-ment/sqlitrest/pkg/auth"
@@ -16,6 +18,30 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := hashPassword(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		if err := runAPIKeyCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "codegen" {
+		if err := runCodegenCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Printf("SQLitREST v%s - SQLite REST API Server\n", version)
 
 	if err := server.Start(); err != nil {
@@ -24,6 +50,34 @@ func main() {
 	}
 }
 
+// hashPassword lit un mot de passe sur l'entrée standard et affiche son hash.
+// Le mot de passe n'est jamais lu depuis les arguments : ils restent visibles
+// dans l'historique du shell et la liste des processus.
+func hashPassword(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("hash-password reads the password from stdin, not from arguments\nUsage: sqlitrest hash-password < password.txt")
+	}
+
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password: ")
+	}
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return fmt.Errorf("empty password")
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
 func generateTestToken() {
 	config := auth.JWTConfig{
 		Enabled:   true,
@@ -7,6 +7,8 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/magefile/mage v1.15.0
 	github.com/pelletier/go-toml/v2 v2.2.2
+	golang.org/x/crypto v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.37.1
 )
 
@@ -0,0 +1,35 @@
+package explorer
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Les fichiers de l'explorateur sont embarqués dans le binaire : aucun CDN
+//
+//go:embed static
+var static embed.FS
+
+// Handler sert l'explorateur d'API sous prefix (ex: "/_docs")
+func Handler(prefix string) http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) // le répertoire est embarqué à la compilation
+	}
+	fileServer := http.StripPrefix(prefix, http.FileServer(http.FS(files)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Les ressources sont relatives : /_docs doit devenir /_docs/
+		if req.URL.Path == prefix {
+			http.Redirect(w, req, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		// Scripts et requêtes limités au serveur lui-même
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		fileServer.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,317 @@
+// SQLitREST Explorer : parcours en lecture seule des tables et fonctions
+// décrites par les documents OpenAPI de chaque base.
+"use strict";
+
+const OPERATORS = ["eq", "neq", "gt", "gte", "lt", "lte", "like", "in", "is"];
+const TOKEN_KEY = "sqlitrest.explorer.token";
+
+const state = {
+  databases: [],
+  database: "",
+  spec: null,
+  resource: null, // { kind: "table" | "function", name, path, operation }
+};
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === "class") node.className = value;
+    else if (key in node) node[key] = value;
+    else node.setAttribute(key, value);
+  }
+  for (const child of children) {
+    node.append(child instanceof Node ? child : document.createTextNode(String(child)));
+  }
+  return node;
+}
+
+// Le token reste dans l'onglet (sessionStorage), jamais dans l'URL
+function token() {
+  return sessionStorage.getItem(TOKEN_KEY) || "";
+}
+
+function headers() {
+  const value = token();
+  if (!value) return {};
+  // Les clés API (sqlr_...) utilisent le schéma ApiKey, les JWT le schéma Bearer
+  return { Authorization: value.startsWith("sqlr_") ? `ApiKey ${value}` : `Bearer ${value}` };
+}
+
+async function getJSON(url) {
+  const response = await fetch(url, { headers: headers(), credentials: "omit" });
+  const text = await response.text();
+  let body = null;
+  try {
+    body = text ? JSON.parse(text) : null;
+  } catch (err) {
+    body = text;
+  }
+  if (!response.ok) {
+    let message = text || response.statusText;
+    if (body && body.code && body.message) message = body.details ? `${body.message}: ${body.details}` : body.message;
+    throw new Error(`${response.status}: ${message}`);
+  }
+  return { body, response };
+}
+
+function showMessage(text, isError) {
+  $("builder").hidden = true;
+  $("message").hidden = false;
+  $("message").className = isError ? "error" : "";
+  $("message").textContent = text;
+}
+
+async function loadContext() {
+  try {
+    const { body } = await getJSON("context");
+    state.databases = body.databases;
+    $("role").textContent = body.role || "anonymous";
+
+    const select = $("database");
+    select.replaceChildren(...state.databases.map((name) => el("option", { value: name }, name)));
+    if (!state.databases.includes(state.database)) {
+      state.database = state.databases.includes("main") ? "main" : state.databases[0] || "";
+    }
+    select.value = state.database;
+    await loadSpec();
+  } catch (err) {
+    $("role").textContent = "?";
+    showMessage(`Cannot load databases: ${err.message}`, true);
+  }
+}
+
+async function loadSpec() {
+  $("tables").replaceChildren();
+  $("functions").replaceChildren();
+  $("spec-link").href = `../${encodeURIComponent(state.database)}/`;
+  if (!state.database) return;
+
+  try {
+    const { body } = await getJSON(`../${encodeURIComponent(state.database)}/`);
+    state.spec = body;
+  } catch (err) {
+    showMessage(`Cannot load the OpenAPI document: ${err.message}`, true);
+    return;
+  }
+
+  const prefix = `/${state.database}/`;
+  const tables = [];
+  const functions = [];
+  for (const [path, item] of Object.entries(state.spec.paths || {})) {
+    if (path.startsWith("/rpc/")) {
+      functions.push({ kind: "function", name: path.slice(5), path, item });
+    } else if (path.startsWith(prefix)) {
+      tables.push({ kind: "table", name: path.slice(prefix.length), path, item });
+    }
+  }
+  tables.sort((a, b) => a.name.localeCompare(b.name));
+  functions.sort((a, b) => a.name.localeCompare(b.name));
+
+  $("tables").replaceChildren(...tables.map(resourceButton));
+  $("functions").replaceChildren(...functions.map(resourceButton));
+  if (tables.length === 0 && functions.length === 0) {
+    showMessage("Nothing is readable with the current role.", false);
+  } else {
+    showMessage("Select a table or a function.", false);
+  }
+}
+
+function resourceButton(resource) {
+  // Seules les lectures sont proposées : GET sur les tables et fonctions non volatiles
+  resource.operation = resource.item.get;
+  const button = el("button", { type: "button", disabled: !resource.operation }, resource.name);
+  if (!resource.operation) button.title = "No read access (or POST-only function)";
+  button.addEventListener("click", () => {
+    document.querySelectorAll("nav li button.active").forEach((b) => b.classList.remove("active"));
+    button.classList.add("active");
+    selectResource(resource);
+  });
+  return el("li", {}, button);
+}
+
+function tableSchema(name) {
+  return (state.spec.components.schemas || {})[name] || { properties: {} };
+}
+
+function selectResource(resource) {
+  state.resource = resource;
+  $("message").hidden = true;
+  $("builder").hidden = false;
+  $("results").replaceChildren();
+  $("status").textContent = "";
+  $("resource-title").textContent = resource.path;
+  $("offset").value = 0;
+
+  const columns = resource.kind === "table" ? Object.keys(tableSchema(resource.name).properties || {}).sort() : [];
+  const description = resource.kind === "table" ? tableSchema(resource.name).description : resource.operation.summary;
+  $("resource-description").textContent = description || "";
+
+  // Colonnes (select=)
+  $("columns-fieldset").hidden = columns.length === 0;
+  $("columns").replaceChildren(
+    ...columns.map((column) => el("label", {}, el("input", { type: "checkbox", value: column, checked: true }), ` ${column}`))
+  );
+
+  // Paramètres déclarés des fonctions
+  const params = (resource.operation.parameters || []).filter((p) => p.in === "query" && p.name);
+  $("params-fieldset").hidden = params.length === 0;
+  $("params").replaceChildren(
+    ...params.map((p) => {
+      const type = p.schema && p.schema.type ? p.schema.type : "json";
+      const input = el("input", { type: "text", name: p.name, placeholder: type });
+      if (p.schema && p.schema.default !== undefined) input.value = p.schema.default;
+      return el("div", { class: "row" }, el("label", {}, p.required ? `${p.name} *` : p.name), input);
+    })
+  );
+
+  // Filtres et tri sur les colonnes (tables et fonctions retournant une table)
+  const composable = resource.kind === "table" || hasRef(resource.operation, "select");
+  $("filters-fieldset").hidden = !composable;
+  $("filters").replaceChildren();
+  const order = $("order-column");
+  order.replaceChildren(el("option", { value: "" }, "(no order)"), ...columns.map((c) => el("option", { value: c }, c)));
+  order.disabled = !composable;
+  $("order-direction").disabled = !composable;
+  $("limit").disabled = !composable;
+  $("offset").disabled = !composable;
+
+  updateURL();
+}
+
+function hasRef(operation, name) {
+  return (operation.parameters || []).some((p) => p.$ref === `#/components/parameters/${name}`);
+}
+
+function addFilter() {
+  const columns = state.resource.kind === "table" ? Object.keys(tableSchema(state.resource.name).properties || {}).sort() : [];
+  const column = columns.length
+    ? el("select", {}, ...columns.map((c) => el("option", { value: c }, c)))
+    : el("input", { type: "text", placeholder: "column" });
+  const operator = el("select", {}, ...OPERATORS.map((op) => el("option", { value: op }, op)));
+  const value = el("input", { type: "text", placeholder: "value (like: %text%, in: a,b, is: null)" });
+  const remove = el("button", { type: "button" }, "Remove");
+  const row = el("div", { class: "row filter" }, column, operator, value, remove);
+  remove.addEventListener("click", () => {
+    row.remove();
+    updateURL();
+  });
+  $("filters").append(row);
+  updateURL();
+}
+
+// buildURL construit l'URL de type PostgREST à partir du formulaire
+function buildURL() {
+  const resource = state.resource;
+  const query = new URLSearchParams();
+
+  for (const input of $("params").querySelectorAll("input")) {
+    if (input.value !== "") query.append(input.name, input.value);
+  }
+
+  const boxes = [...$("columns").querySelectorAll("input")];
+  const selected = boxes.filter((b) => b.checked).map((b) => b.value);
+  if (boxes.length && selected.length && selected.length < boxes.length) {
+    query.append("select", selected.join(","));
+  }
+
+  if (!$("filters-fieldset").hidden) {
+    for (const row of $("filters").querySelectorAll(".filter")) {
+      const [column, operator, value] = row.querySelectorAll("select, input");
+      if (!column.value) continue;
+      query.append(column.value, `${operator.value}.${value.value}`);
+    }
+  }
+
+  if (!$("order-column").disabled) {
+    if ($("order-column").value) query.append("order", `${$("order-column").value}.${$("order-direction").value}`);
+    if ($("limit").value) query.append("limit", $("limit").value);
+    if (Number($("offset").value) > 0) query.append("offset", $("offset").value);
+  }
+
+  const path = resource.path.split("/").map(encodeURIComponent).join("/");
+  const search = query.toString();
+  return `${location.origin}${path}${search ? "?" + search : ""}`;
+}
+
+function updateURL() {
+  if (state.resource) $("url").value = buildURL();
+}
+
+async function run() {
+  const url = buildURL();
+  $("url").value = url;
+  $("status").textContent = "Loading…";
+  $("results").replaceChildren();
+
+  const started = performance.now();
+  try {
+    const { body } = await getJSON(url);
+    const elapsed = Math.round(performance.now() - started);
+    const rows = Array.isArray(body) ? body : null;
+    $("status").textContent = rows ? `${rows.length} row(s) in ${elapsed} ms` : `Result in ${elapsed} ms`;
+    $("results").append(rows ? renderTable(rows) : el("pre", {}, JSON.stringify(body, null, 2)));
+  } catch (err) {
+    $("status").textContent = "";
+    $("results").append(el("p", { class: "error" }, err.message));
+  }
+}
+
+// renderTable affiche les lignes ; les valeurs sont insérées en texte, jamais en HTML
+function renderTable(rows) {
+  if (rows.length === 0) return el("p", { class: "muted" }, "No rows.");
+
+  const columns = [];
+  for (const row of rows) {
+    for (const key of Object.keys(row)) if (!columns.includes(key)) columns.push(key);
+  }
+
+  const head = el("tr", {}, ...columns.map((c) => el("th", {}, c)));
+  const body = rows.map((row) =>
+    el(
+      "tr",
+      {},
+      ...columns.map((c) => {
+        const value = row[c];
+        if (value === null || value === undefined) return el("td", { class: "null" }, "null");
+        return el("td", {}, typeof value === "object" ? JSON.stringify(value) : value);
+      })
+    )
+  );
+  return el("table", {}, el("thead", {}, head), el("tbody", {}, ...body));
+}
+
+function page(direction) {
+  const limit = Number($("limit").value) || 25;
+  $("offset").value = Math.max(0, (Number($("offset").value) || 0) + direction * limit);
+  run();
+}
+
+$("token-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const value = $("token").value.trim().replace(/^(Bearer|ApiKey)\s+/i, "");
+  if (value) sessionStorage.setItem(TOKEN_KEY, value);
+  $("token").value = "";
+  loadContext();
+});
+
+$("clear-token").addEventListener("click", () => {
+  sessionStorage.removeItem(TOKEN_KEY);
+  loadContext();
+});
+
+$("database").addEventListener("change", (event) => {
+  state.database = event.target.value;
+  loadSpec();
+});
+
+$("builder").addEventListener("input", updateURL);
+$("builder").addEventListener("change", updateURL);
+$("add-filter").addEventListener("click", addFilter);
+$("run").addEventListener("click", run);
+$("previous").addEventListener("click", () => page(-1));
+$("next").addEventListener("click", () => page(1));
+$("copy-url").addEventListener("click", () => navigator.clipboard.writeText($("url").value));
+
+loadContext();
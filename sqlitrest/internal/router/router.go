@@ -1,19 +1,27 @@
 package router
 
 import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cl-ment/sqlitrest/internal/explorer"
 	"github.com/cl-ment/sqlitrest/pkg/auth"
 	"github.com/cl-ment/sqlitrest/pkg/config"
 	"github.com/cl-ment/sqlitrest/pkg/db"
 	"github.com/cl-ment/sqlitrest/pkg/engine"
 	"github.com/cl-ment/sqlitrest/pkg/openapi"
 	"github.com/cl-ment/sqlitrest/pkg/policies"
+	"github.com/cl-ment/sqlitrest/pkg/ratelimit"
 	"github.com/cl-ment/sqlitrest/pkg/rpc"
 	"github.com/go-chi/chi/v5"
 )
@@ -25,40 +33,136 @@ type Router struct {
 	parser       *engine.QueryParser
 	builder      *engine.SQLBuilder
 	jwtManager   *auth.JWTManager
+	grants       *auth.GrantManager
+	sessions     *auth.SessionManager
+	revocations  *auth.RevocationStore
+	limiter      *ratelimit.Limiter
 	policyEngine *policies.PolicyEngine
-	openapiGen   *openapi.OpenAPIGenerator
+	openapiGens  map[string]*openapi.OpenAPIGenerator
 	rpcHandler   *rpc.RPCHandler
-	embedding    *engine.ResourceEmbedding
-	schemaCache  *engine.SchemaCache
+	schemaCaches map[string]*engine.SchemaCache // un cache de schéma par base
+	cursors      *engine.CursorCodec
+	errors       *engine.ErrorHandler
 }
 
-func New(dbManager *db.Manager, cfg *config.Config) *Router {
+// New crée le routeur ; une configuration d'authentification invalide empêche
+// le démarrage plutôt que de servir des requêtes sans vérification des tokens
+func New(dbManager *db.Manager, cfg *config.Config) (*Router, error) {
 	// Créer le gestionnaire JWT
 	jwtManager, err := auth.NewJWTManager(cfg.Auth.JWT)
 	if err != nil {
-		log.Printf("Failed to create JWT manager: %v", err)
+		return nil, fmt.Errorf("failed to create JWT manager: %w", err)
 	}
 
+	// Créer le gestionnaire de droits (config + table _grants de la base principale)
+	grants := auth.NewGrantManager(nil, cfg.Auth.Grants)
+
 	// Créer le moteur de politiques
 	var policyEngine *policies.PolicyEngine
-	var openapiGen *openapi.OpenAPIGenerator
 	var rpcHandler *rpc.RPCHandler
-	var embedding *engine.ResourceEmbedding
 	var schemaCache *engine.SchemaCache
+	var revocations *auth.RevocationStore
 
 	if db, err := dbManager.GetDB("main"); err == nil {
+		grants = auth.NewGrantManager(db.Writer, cfg.Auth.Grants)
+
+		if apiKeys, err := auth.NewAPIKeyManager(db.Writer); err == nil {
+			jwtManager.SetAPIKeyManager(apiKeys)
+		} else {
+			log.Printf("Failed to create API key manager: %v", err)
+		}
+
+		if revocations, err = auth.NewRevocationStore(db.Writer); err == nil {
+			jwtManager.SetRevocationStore(revocations)
+			revocations.StartReloading()
+		} else {
+			log.Printf("Failed to create token revocation store: %v", err)
+		}
+
 		policyEngine = policies.NewPolicyEngine(db.Writer)
+		policyEngine.SetSources(cfg.Policies)
 		if err := policyEngine.LoadPolicies(); err != nil {
-			log.Printf("Failed to load policies: %v", err)
+			// Démarrer sans politiques exposerait toutes les lignes
+			return nil, fmt.Errorf("failed to load policies: %w", err)
 		}
+		policyEngine.StartWatching()
 
-		openapiGen = openapi.NewOpenAPIGenerator(db.Writer)
-		rpcHandler = rpc.NewRPCHandler(db.Writer, jwtManager)
-		embedding = engine.NewResourceEmbedding(db.Writer)
+		rpcHandler = rpc.NewRPCHandler(db.Writer, jwtManager, grants, policyEngine)
+		rpcHandler.SetConfig(cfg.RPC)
+		rpcHandler.SetReaderPool(db)
+		if err := rpcHandler.LoadFunctions(); err != nil {
+			log.Printf("Failed to load RPC functions: %v", err)
+		}
+		rpcHandler.StartWatching()
 		// Schema cache avec TTL de 5 minutes
 		schemaCache = engine.NewSchemaCache(db.Writer, 5*time.Minute)
 	}
 
+	// Un cache de schéma et un document OpenAPI par base ; les fonctions RPC sont
+	// servies par la base principale
+	schemaCaches := make(map[string]*engine.SchemaCache)
+	openapiGens := make(map[string]*openapi.OpenAPIGenerator)
+	for dbName, database := range dbManager.ListDatabases() {
+		cache := schemaCache
+		if dbName != "main" || cache == nil {
+			cache = engine.NewSchemaCache(database.Reader(), 5*time.Minute)
+		}
+		schemaCaches[dbName] = cache
+		gen := openapi.NewOpenAPIGenerator(dbName, cache)
+		gen.SetServerURL(cfg.Server.URL())
+		gen.SetReadOnly(database.Writer == nil)
+		gen.SetJWTEnabled(cfg.Auth.JWT.Enabled)
+		gen.SetAccessControl(grants, policyEngine)
+		if dbName == "main" && rpcHandler != nil {
+			gen.SetRPCHandler(rpcHandler)
+		}
+		openapiGens[dbName] = gen
+	}
+
+	if err := grants.LoadGrants(); err != nil {
+		log.Printf("Failed to load grants: %v", err)
+	}
+
+	// Endpoints de login intégrés (optionnels)
+	var sessions *auth.SessionManager
+	if cfg.Auth.Login.Enabled {
+		loginDB := cfg.Auth.Login.Database
+		if loginDB == "" {
+			loginDB = "main"
+		}
+		if db, err := dbManager.GetDB(loginDB); err == nil {
+			if sessions, err = auth.NewSessionManager(db.Writer, jwtManager, cfg.Auth.Login); err != nil {
+				log.Printf("Failed to enable login endpoints: %v", err)
+			}
+		} else {
+			log.Printf("Failed to enable login endpoints: %v", err)
+		}
+	}
+
+	// Limiteur de débit (optionnel)
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		var limitDB *sql.DB
+		if cfg.RateLimit.Persist {
+			name := cfg.RateLimit.Database
+			if name == "" {
+				name = "main"
+			}
+			if db, err := dbManager.GetDB(name); err == nil {
+				limitDB = db.Writer
+			}
+		}
+		if limiter, err = ratelimit.NewLimiter(cfg.RateLimit, limitDB); err != nil {
+			log.Printf("Failed to enable rate limiting: %v", err)
+		}
+	}
+
+	// Curseurs de pagination signés
+	cursors, err := engine.NewCursorCodec(cfg.Pagination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable cursor pagination: %w", err)
+	}
+
 	r := &Router{
 		dbManager:    dbManager,
 		config:       cfg,
@@ -66,18 +170,28 @@ func New(dbManager *db.Manager, cfg *config.Config) *Router {
 		parser:       engine.NewQueryParser(),
 		builder:      engine.NewSQLBuilder(),
 		jwtManager:   jwtManager,
+		grants:       grants,
+		sessions:     sessions,
+		revocations:  revocations,
+		limiter:      limiter,
 		policyEngine: policyEngine,
-		openapiGen:   openapiGen,
+		openapiGens:  openapiGens,
 		rpcHandler:   rpcHandler,
-		embedding:    embedding,
-		schemaCache:  schemaCache,
+		schemaCaches: schemaCaches,
+		cursors:      cursors,
+		errors:       engine.NewErrorHandler(),
 	}
 
 	r.setupRoutes()
-	return r
+	return r, nil
 }
 
 func (r *Router) setupRoutes() {
+	// Limitation de débit (authentifie la requête une seule fois pour les handlers)
+	if r.limiter != nil {
+		r.chi.Use(r.limiter.Middleware(r.jwtManager))
+	}
+
 	// Health check
 	r.chi.Get("/health", r.handleHealth)
 
@@ -90,14 +204,30 @@ func (r *Router) setupRoutes() {
 		debugRouter.Get("/auth", r.handleDebugAuth)
 	})
 
+	// Auth endpoints
+	r.chi.Route("/auth", func(authRouter chi.Router) {
+		if r.sessions != nil {
+			authRouter.Post("/login", r.handleLogin)
+			authRouter.Post("/refresh", r.handleRefresh)
+			authRouter.Post("/logout", r.handleLogout)
+		}
+		authRouter.Post("/revoke", r.handleRevoke)
+	})
+
 	// OpenAPI endpoint
-	r.chi.Get("/", r.handleOpenAPI)
-	r.chi.Get("/swagger.json", r.handleOpenAPIJSON)
+	r.chi.Get("/", r.handleOpenAPI("main"))
+	r.chi.Get("/swagger.json", r.handleOpenAPI("main"))
+
+	// Explorateur d'API embarqué
+	r.chi.Route("/_docs", func(docsRouter chi.Router) {
+		docsRouter.Get("/context", r.handleDocsContext)
+		docsRouter.Handle("/*", explorer.Handler("/_docs"))
+	})
 
 	// RPC endpoints
 	r.chi.Route("/rpc", func(rpcRouter chi.Router) {
-		rpcRouter.Get("/*", r.rpcHandler.HandleRPC)
-		rpcRouter.Post("/*", r.rpcHandler.HandleRPC)
+		rpcRouter.Get("/*", r.handleRPC)
+		rpcRouter.Post("/*", r.handleRPC)
 		rpcRouter.Get("/", r.handleRPCList)
 	})
 
@@ -105,7 +235,10 @@ func (r *Router) setupRoutes() {
 	databases := r.dbManager.ListDatabases()
 	for dbName := range databases {
 		r.chi.Route("/"+dbName, func(dbRouter chi.Router) {
+			dbRouter.Get("/", r.handleOpenAPI(dbName))
+			dbRouter.Get("/_schema/{table}", r.handleTableSchema(dbName))
 			dbRouter.Get("/*", r.handleTableQuery(dbName))
+			dbRouter.Head("/*", r.handleTableQuery(dbName))
 			dbRouter.Post("/*", r.handleTableCreate(dbName))
 			dbRouter.Patch("/*", r.handleTableUpdate(dbName))
 			dbRouter.Delete("/*", r.handleTableDelete(dbName))
@@ -171,7 +304,7 @@ func (r *Router) handleDebugAuth(w http.ResponseWriter, req *http.Request) {
 
 	authCtx, err := r.jwtManager.AuthenticateRequest(req)
 	if err != nil {
-		w.Write([]byte(`{"authenticated":false,"error":"` + err.Error() + `"}`))
+		json.NewEncoder(w).Encode(map[string]interface{}{"authenticated": false, "error": err.Error()})
 		return
 	}
 
@@ -181,132 +314,764 @@ func (r *Router) handleDebugAuth(w http.ResponseWriter, req *http.Request) {
 		"role":          authCtx.Role,
 		"tenant_id":     authCtx.TenantID,
 		"permissions":   authCtx.Permissions,
+		"claims":        authCtx.Claims,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-func (r *Router) handleOpenAPI(w http.ResponseWriter, req *http.Request) {
-	if r.openapiGen == nil {
-		http.Error(w, `{"error":"OpenAPI generator not available"}`, http.StatusServiceUnavailable)
+func (r *Router) handleLogin(w http.ResponseWriter, req *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		engine.WriteError(w, r.errors.BodyError(err))
+		return
+	}
+
+	identity := body[r.sessions.IdentityField()]
+	tokens, err := r.sessions.Login(identity, body["password"])
+	if err != nil {
+		r.writeSessionError(w, err)
+		return
+	}
+
+	r.writeTokenPair(w, tokens)
+}
+
+func (r *Router) handleRefresh(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		engine.WriteError(w, r.errors.BodyError(err))
+		return
+	}
+
+	tokens, err := r.sessions.Refresh(body.RefreshToken)
+	if err != nil {
+		r.writeSessionError(w, err)
+		return
+	}
+
+	r.writeTokenPair(w, tokens)
+}
+
+func (r *Router) handleLogout(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		engine.WriteError(w, r.errors.BodyError(err))
+		return
+	}
+
+	if err := r.sessions.Logout(body.RefreshToken); err != nil {
+		r.writeSessionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRevoke révoque un token (par jti ou token complet) ou tous les tokens
+// et sessions d'un utilisateur. Réservé aux détenteurs de "revoke:tokens" (et aux admins).
+func (r *Router) handleRevoke(w http.ResponseWriter, req *http.Request) {
+	if r.revocations == nil {
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeConnection, "Token revocation not available"))
 		return
 	}
 
-	doc, err := r.openapiGen.Generate()
+	authCtx, err := r.jwtManager.AuthenticateRequest(req)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"Failed to generate OpenAPI: %s"}`, err.Error()), http.StatusInternalServerError)
+		engine.WriteError(w, r.errors.AuthError(err.Error()))
+		return
+	}
+	if !authCtx.HasPermission("revoke:tokens") {
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypePermission, "Permission denied", "revoke:tokens is required"))
+		return
+	}
+
+	var body struct {
+		Token     string `json:"token"`
+		JTI       string `json:"jti"`
+		ExpiresAt int64  `json:"expires_at"` // timestamp Unix, optionnel
+		UserID    string `json:"user_id"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		engine.WriteError(w, r.errors.BodyError(err))
 		return
 	}
 
+	response := map[string]interface{}{}
+	switch {
+	case body.UserID != "":
+		notBefore, err := r.revocations.RevokeUser(body.UserID, body.Reason)
+		if err != nil {
+			engine.WriteError(w, err)
+			return
+		}
+		if r.sessions != nil {
+			if err := r.sessions.RevokeUser(body.UserID); err != nil {
+				log.Printf("Failed to revoke sessions of user %s: %v", body.UserID, err)
+			}
+		}
+		response["user_id"] = body.UserID
+		response["not_before"] = notBefore
+
+	case body.Token != "" || body.JTI != "":
+		jti, userID := body.JTI, ""
+		var expiresAt time.Time
+		if body.ExpiresAt > 0 {
+			expiresAt = time.Unix(body.ExpiresAt, 0)
+		}
+		if body.Token != "" {
+			claims, err := r.jwtManager.ValidateToken(body.Token)
+			if err != nil {
+				engine.WriteError(w, r.errors.ValidationError("token", err.Error()))
+				return
+			}
+			if claims.ID == "" {
+				engine.WriteError(w, r.errors.ValidationError("token", "Token has no jti, revoke its user instead"))
+				return
+			}
+			jti, userID = claims.ID, claims.UserID
+			if claims.ExpiresAt != nil {
+				expiresAt = claims.ExpiresAt.Time
+			}
+		}
+		if err := r.revocations.RevokeToken(jti, userID, expiresAt, body.Reason); err != nil {
+			engine.WriteError(w, err)
+			return
+		}
+		response["jti"] = jti
+
+	default:
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeInvalidBody, "token, jti or user_id is required"))
+		return
+	}
+
+	response["revoked"] = true
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeTokenPair écrit une réponse de login/refresh (jamais mise en cache)
+func (r *Router) writeTokenPair(w http.ResponseWriter, tokens *auth.TokenPair) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(doc)
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// writeSessionError distingue les identifiants invalides des erreurs internes
+func (r *Router) writeSessionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrInvalidCredentials) || errors.Is(err, auth.ErrInvalidRefreshToken) {
+		engine.WriteError(w, r.errors.AuthError(err.Error()))
+		return
+	}
+
+	log.Printf("Session error: %v", err)
+	engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeInternal, "Authentication unavailable"))
+}
+
+// handleOpenAPI sert la spécification OpenAPI d'une base
+func (r *Router) handleOpenAPI(dbName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		gen, exists := r.openapiGens[dbName]
+		if !exists {
+			engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeSchemaCache, "OpenAPI generator not available"))
+			return
+		}
+
+		// Le document ne décrit que ce que le rôle de l'appelant peut utiliser
+		authCtx, err := r.jwtManager.AuthenticateRequest(req)
+		if err != nil {
+			engine.WriteError(w, r.errors.AuthError(err.Error()))
+			return
+		}
+
+		doc, err := gen.Generate(authCtx)
+		if err != nil {
+			engine.WriteError(w, r.errors.WrapError(err, "Failed to generate OpenAPI"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	}
+}
+
+// handleTableSchema sert le JSON Schema d'une table (/{db}/_schema/{table})
+func (r *Router) handleTableSchema(dbName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		gen, exists := r.openapiGens[dbName]
+		if !exists {
+			engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeSchemaCache, "Schema not available"))
+			return
+		}
+
+		authCtx, err := r.jwtManager.AuthenticateRequest(req)
+		if err != nil {
+			engine.WriteError(w, r.errors.AuthError(err.Error()))
+			return
+		}
+
+		// Le schéma est visible avec un droit de lecture ou d'écriture sur la table
+		table := chi.URLParam(req, "table")
+		if !r.grants.Check(authCtx, auth.PrivilegeSelect, table) &&
+			!r.grants.Check(authCtx, auth.PrivilegeInsert, table) &&
+			!r.grants.Check(authCtx, auth.PrivilegeUpdate, table) {
+			engine.WriteError(w, r.errors.PermissionError("SELECT, INSERT or UPDATE", table, authCtx.Role))
+			return
+		}
+
+		if _, err := r.schemaCaches[dbName].SchemaVersion(); err != nil {
+			log.Printf("Failed to check schema version: %v", err)
+		}
+		schema, found, err := gen.JSONSchema(table)
+		if err != nil {
+			engine.WriteError(w, r.errors.WrapError(err, "Failed to generate schema"))
+			return
+		}
+		if !found {
+			engine.WriteError(w, r.errors.NotFoundError("table "+table))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/schema+json")
+		json.NewEncoder(w).Encode(schema)
+	}
+}
+
+// tableSchema retourne le schéma d'une table depuis le cache de sa base,
+// rechargé si le schéma SQLite a changé
+func (r *Router) tableSchema(dbName, table string) (*engine.SchemaInfo, error) {
+	cache, exists := r.schemaCaches[dbName]
+	if !exists {
+		return nil, fmt.Errorf("no schema cache for database %s", dbName)
+	}
+	if _, err := cache.SchemaVersion(); err != nil {
+		return nil, err
+	}
+	return cache.GetSchema(table)
+}
+
+// validateBody vérifie le corps d'une insertion ou d'une mise à jour contre le
+// schéma de la table et répond 400 avec toutes les violations. Les objets et
+// tableaux destinés aux colonnes JSON sont sérialisés en texte.
+func (r *Router) validateBody(w http.ResponseWriter, dbName, table string, data map[string]interface{}, insert bool) bool {
+	schema, err := r.tableSchema(dbName, table)
+	if err != nil {
+		// Table inconnue : l'erreur SQLite est retournée à l'exécution
+		return true
+	}
+
+	var violations []engine.Violation
+	if insert {
+		violations = schema.ValidateInsert(data)
+	} else {
+		violations = schema.ValidateUpdate(data)
+	}
+	if len(violations) > 0 {
+		engine.WriteError(w, r.errors.ViolationsError(table, violations))
+		return false
+	}
+
+	if err := schema.EncodeJSONValues(data); err != nil {
+		engine.WriteError(w, r.errors.ValidationError(table, err.Error()))
+		return false
+	}
+	return true
 }
 
-func (r *Router) handleOpenAPIJSON(w http.ResponseWriter, req *http.Request) {
-	r.handleOpenAPI(w, req)
+// handleDocsContext retourne le rôle de l'appelant et les bases documentées (explorateur)
+func (r *Router) handleDocsContext(w http.ResponseWriter, req *http.Request) {
+	authCtx, err := r.jwtManager.AuthenticateRequest(req)
+	if err != nil {
+		engine.WriteError(w, r.errors.AuthError(err.Error()))
+		return
+	}
+
+	databases := make([]string, 0, len(r.openapiGens))
+	for name := range r.openapiGens {
+		databases = append(databases, name)
+	}
+	sort.Strings(databases)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role":      authCtx.Role,
+		"databases": databases,
+	})
 }
 
 func (r *Router) handleRPCList(w http.ResponseWriter, req *http.Request) {
 	if r.rpcHandler == nil {
-		http.Error(w, `{"error":"RPC handler not available"}`, http.StatusServiceUnavailable)
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeConnection, "RPC handler not available"))
 		return
 	}
 
-	functions := r.rpcHandler.ListFunctions()
+	authCtx, err := r.jwtManager.AuthenticateRequest(req)
+	if err != nil {
+		engine.WriteError(w, r.errors.AuthError(err.Error()))
+		return
+	}
+
+	// Ne lister que les fonctions que le rôle peut exécuter
+	functions := []rpc.RPCFunction{}
+	for _, fn := range r.rpcHandler.ListFunctions() {
+		if r.grants.Check(authCtx, auth.PrivilegeExecute, fn.Name) {
+			functions = append(functions, fn)
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"functions": functions,
 	})
 }
 
+// handleRPC exécute une fonction RPC et négocie le format de la réponse
+// comme pour les tables : CSV et objet unique pour les fonctions retournant une table
+func (r *Router) handleRPC(w http.ResponseWriter, req *http.Request) {
+	result, ok := r.rpcHandler.Invoke(w, req)
+	if !ok {
+		return
+	}
+
+	contentType := r.negotiateContentType(req.Header.Get("Accept"))
+	table, isTable := result.(*engine.QueryResult)
+
+	switch {
+	case isTable && contentType == "text/csv":
+		r.writeCSVResponse(w, table, http.StatusOK)
+	case isTable && contentType == "application/vnd.pgrst.object":
+		r.writeObjectResponse(w, table)
+	case isTable && contentType == "application/json":
+		r.writeJSONResponse(w, table, http.StatusOK)
+	case contentType == "application/json" || contentType == "application/vnd.pgrst.object":
+		// Une fonction retournant une ligne ou une valeur est déjà un objet unique
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	default:
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeNotAcceptable, fmt.Sprintf("None of these media types are available: %s", contentType), "Functions returning a scalar or a row only produce JSON"))
+	}
+}
+
 func (r *Router) handleTableQuery(dbName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		// Authentifier la requête
 		authCtx, err := r.jwtManager.AuthenticateRequest(req)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Authentication failed: %s"}`, err.Error()), http.StatusUnauthorized)
+			engine.WriteError(w, r.errors.AuthError(err.Error()))
 			return
 		}
 
 		// Parser les paramètres de la requête
 		params, err := r.parser.ParseQuery(req.URL.Path, req.URL.Query())
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.QueryStringError(err))
+			return
+		}
+
+		// Plage demandée par l'en-tête Range (alternative à limit/offset)
+		if err := r.parser.ApplyRange(params, req.Header.Get("Range"), req.Header.Get("Range-Unit")); err != nil {
+			engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeInvalidRange, "Requested range not satisfiable", err.Error()))
 			return
 		}
 
-		// Vérifier les permissions d'accès à la table
-		if !authCtx.CanAccessTable(params.Table) {
-			http.Error(w, `{"error":"Access denied to table"}`, http.StatusForbidden)
+		// Vérifier les droits d'accès à la table
+		if !r.checkGrant(w, authCtx, auth.PrivilegeSelect, params.Table) {
 			return
 		}
 
 		// Obtenir la base de données
 		database, err := r.dbManager.GetDB(dbName)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Database %s not found"}`, dbName), http.StatusNotFound)
+			engine.WriteError(w, r.errors.NotFoundError("database "+dbName))
+			return
+		}
+
+		// Pagination par curseur : tri complété par la clé primaire, reprise après le curseur
+		keyset, ok := r.applyCursor(w, dbName, params)
+		if !ok {
 			return
 		}
 
 		// Debug: afficher les paramètres parsés
 		log.Printf("Parsed params: table=%s, filters=%v, auth=%s", params.Table, params.Filters, authCtx.Role)
 
-		// Construire la requête SQL avec embedding support
-		var query string
-		var args []interface{}
-
-		// Utiliser BuildSelectWithEmbedding si embedding est disponible
-		if r.embedding != nil {
-			query, args, err = r.builder.BuildSelectWithEmbedding(params, r.embedding)
-		} else {
-			query, args, err = r.builder.BuildSelect(params)
+		// Relations incluses (select=*,users(name)) : chaque table incluse est lue
+		// et demande le droit SELECT, vérifié avant de construire la requête
+		relations, err := engine.NewResourceEmbedding(database.Writer).ResolveRelations(params)
+		if err != nil {
+			engine.WriteError(w, r.errors.QueryStringError(err))
+			return
+		}
+		embedded := make([]string, 0, len(relations))
+		for _, relation := range relations {
+			if !r.checkGrant(w, authCtx, auth.PrivilegeSelect, relation.Table) {
+				return
+			}
+			embedded = append(embedded, relation.Name)
 		}
 
+		query, args, err := r.builder.BuildSelectWithEmbedding(params, relations)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.QueryStringError(err))
 			return
 		}
 
 		// Appliquer les politiques de sécurité
 		if r.policyEngine != nil {
-			secureQuery, secureArgs, err := r.policyEngine.ApplyPolicies(query, params, authCtx)
+			secureQuery, err := r.policyEngine.ApplyPolicies(query, params, authCtx)
 			if err != nil {
-				http.Error(w, fmt.Sprintf(`{"error":"Policy application failed: %s"}`, err.Error()), http.StatusInternalServerError)
+				engine.WriteError(w, r.errors.WrapError(err, "Policy application failed"))
 				return
 			}
 			query = secureQuery
-			// Combiner les arguments originaux avec les arguments de sécurité
-			args = append(args, secureArgs...)
-			log.Printf("Applied policies, new query: %s, combined args: %v", query, args)
+			log.Printf("Applied policies, new query: %s, args: %v", query, args)
 		}
 
 		// Debug: afficher le SQL généré
 		r.builder.DebugSQLBuilder(params)
 
-		// Exécuter la requête
+		// Gérer les différents Media Types (PostgREST compatible)
+		acceptHeader := req.Header.Get("Accept")
+		contentType := r.negotiateContentType(acceptHeader)
+		paged := contentType == "text/csv" || contentType == "application/json"
+
+		// Comptage demandé par Prefer: count=exact|planned|estimated
 		executor := engine.NewExecutor(database.Writer)
-		result, err := executor.ExecuteSelect(query, args)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Query execution failed: %s"}`, err.Error()), http.StatusInternalServerError)
+		executor.SetJSONColumns(embedded)
+		var total *int64
+		if paged {
+			total, err = r.countRows(executor, params, authCtx, engine.ParseCountPreference(req.Header.Get("Prefer")))
+			if err != nil {
+				engine.WriteError(w, r.errors.DatabaseError("Count", err))
+				return
+			}
+		}
+
+		// HEAD : en-têtes seuls, les lignes de la page sont comptées sans être lues
+		if req.Method == http.MethodHead && paged {
+			rows, err := executor.ExecuteCount("SELECT COUNT(*) FROM ("+query+")", args)
+			if err != nil {
+				engine.WriteError(w, r.errors.DatabaseError("Query", err))
+				return
+			}
+			if status, ok := r.writeContentRange(w, params, int(rows), total); ok {
+				w.Header().Set("Content-Type", contentType)
+				w.WriteHeader(status)
+			}
 			return
 		}
 
-		// Gérer les différents Media Types (PostgREST compatible)
-		acceptHeader := req.Header.Get("Accept")
-		contentType := r.negotiateContentType(acceptHeader)
+		// Exécuter la requête ; une lecture sans limite en JSON ou CSV passe en
+		// streaming si elle dépasse le seuil
+		var result *engine.QueryResult
+		if paged && params.Limit == nil {
+			if result, ok = r.selectOrStream(w, executor, query, args, contentType, params, total); !ok {
+				return
+			}
+		} else if result, err = executor.ExecuteSelect(query, args); err != nil {
+			engine.WriteError(w, r.errors.DatabaseError("Query", err))
+			return
+		}
+
+		// Lien vers la page suivante quand la page est pleine
+		if keyset {
+			r.writeNextLink(w, req, params, result)
+		}
 
 		switch contentType {
 		case "text/csv":
-			r.writeCSVResponse(w, result)
+			if status, ok := r.writeContentRange(w, params, len(result.Rows), total); ok {
+				r.writeCSVResponse(w, result, status)
+			}
 		case "application/vnd.pgrst.object":
 			r.writeObjectResponse(w, result)
 		case "application/vnd.pgrst.plan":
 			r.writePlanResponse(w, query, args)
 		default:
 			// JSON par défaut
-			r.writeJSONResponse(w, result)
+			if status, ok := r.writeContentRange(w, params, len(result.Rows), total); ok {
+				r.writeJSONResponse(w, result, status)
+			}
+		}
+	}
+}
+
+// selectOrStream exécute une lecture sans limite. Les lignes sont matérialisées
+// jusqu'au seuil de streaming ; au-delà, la réponse est envoyée en streaming et
+// false est retourné, comme en cas d'erreur déjà signalée au client.
+func (r *Router) selectOrStream(w http.ResponseWriter, executor *engine.Executor, query string, args []interface{}, contentType string, params *engine.QueryParameters, total *int64) (*engine.QueryResult, bool) {
+	reader, err := executor.QuerySelect(query, args)
+	if err != nil {
+		engine.WriteError(w, r.errors.DatabaseError("Query", err))
+		return nil, false
+	}
+	defer reader.Close()
+
+	threshold := r.config.Pagination.StreamRows()
+	var buffered [][]interface{}
+	for reader.Next() {
+		buffered = append(buffered, append([]interface{}{}, reader.Values()...))
+		if len(buffered) > threshold {
+			r.streamRows(w, reader, buffered, contentType, params, total)
+			return nil, false
+		}
+	}
+	if err := reader.Err(); err != nil {
+		engine.WriteError(w, r.errors.DatabaseError("Query", err))
+		return nil, false
+	}
+
+	result := &engine.QueryResult{Columns: reader.Columns(), Count: len(buffered)}
+	for _, values := range buffered {
+		row := make(map[string]interface{}, len(values))
+		for i, column := range result.Columns {
+			row[column] = values[i]
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, true
+}
+
+// streamRows envoie une lecture en streaming (Transfer-Encoding: chunked) : les
+// lignes déjà lues puis le reste du curseur, encodées au fil de la lecture et
+// vidées vers le client par blocs. La dernière ligne n'est pas connue avant la
+// fin du corps : le Content-Range est déduit du total et de l'offset quand un
+// comptage est demandé ("10-3572/3573"), omis sinon.
+// Une erreur survenue après l'envoi du statut interrompt le corps et est
+// transmise dans les trailers X-Error-*.
+func (r *Router) streamRows(w http.ResponseWriter, reader *engine.RowReader, buffered [][]interface{}, contentType string, params *engine.QueryParameters, total *int64) {
+	offset := 0
+	if params.Offset != nil {
+		offset = *params.Offset
+	}
+	status := http.StatusOK
+	if total != nil && offset > 0 {
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Range-Unit", "items")
+	if total != nil {
+		w.Header().Set("Content-Range", engine.ContentRange(offset, int(*total)-offset, total))
+	}
+	w.Header().Set("Trailer", engine.ErrorTrailers)
+	w.WriteHeader(status)
+
+	flusher, _ := w.(http.Flusher)
+	out := bufio.NewWriterSize(w, 32*1024)
+	csvOut := csv.NewWriter(out)
+	columns := reader.Columns()
+	encoder := engine.NewRowEncoder(columns)
+	var line []byte
+
+	// Écrire une ligne ; le tampon est vidé vers le client toutes les 1000 lignes
+	written := 0
+	writeRow := func(values []interface{}) error {
+		if contentType == "text/csv" {
+			if err := csvOut.Write(csvRecord(values)); err != nil {
+				return err
+			}
+		} else {
+			line = line[:0]
+			if written > 0 {
+				line = append(line, ',')
+			}
+			var err error
+			if line, err = encoder.AppendJSON(line, values); err != nil {
+				return err
+			}
+			if _, err := out.Write(line); err != nil {
+				return err
+			}
+		}
+		written++
+		if written%1000 == 0 {
+			csvOut.Flush()
+			if err := csvOut.Error(); err != nil {
+				return err
+			}
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	}
+
+	// Ouverture : en-tête CSV ou tableau JSON
+	if contentType == "text/csv" {
+		csvOut.Write(columns)
+	} else {
+		out.WriteByte('[')
+	}
+
+	err := func() error {
+		for _, values := range buffered {
+			if err := writeRow(values); err != nil {
+				return err
+			}
+		}
+		for reader.Next() {
+			if err := writeRow(reader.Values()); err != nil {
+				return err
+			}
+		}
+		return reader.Err()
+	}()
+
+	csvOut.Flush()
+	if err != nil {
+		log.Printf("Streaming interrupted after %d rows: %v", written, err)
+		out.Flush()
+		engine.WriteErrorTrailers(w, r.errors.DatabaseError("Query", err))
+		return
+	}
+
+	if contentType != "text/csv" {
+		out.WriteByte(']')
+	}
+	out.Flush()
+}
+
+// countRows compte les lignes d'une lecture selon le mode demandé ; nil sans
+// comptage. Le comptage exact reprend les filtres et les politiques de la requête,
+// sans pagination ; les modes planned et estimated retombent sur un comptage exact
+// si la table n'a pas de statistiques ou si des politiques SELECT filtrent ses
+// lignes pour l'appelant, et le mode estimated compte exactement sous le seuil configuré.
+func (r *Router) countRows(executor *engine.Executor, params *engine.QueryParameters, authCtx *auth.AuthContext, mode engine.CountMode) (*int64, error) {
+	if mode == engine.CountNone {
+		return nil, nil
+	}
+
+	// Les statistiques portent sur toute la table : pas d'estimation quand des
+	// politiques SELECT filtrent les lignes visibles par l'appelant
+	restricted := r.policyEngine != nil && r.policyEngine.Restricts(params.Table, "SELECT", authCtx)
+
+	if mode != engine.CountExact && !restricted {
+		estimate, found, err := executor.EstimateCount(params)
+		if err != nil {
+			return nil, err
+		}
+		if found && (mode == engine.CountPlanned || estimate > r.config.Pagination.CountThreshold()) {
+			return &estimate, nil
+		}
+	}
+
+	query, args, err := r.builder.BuildCount(params)
+	if err != nil {
+		return nil, err
+	}
+	if r.policyEngine != nil {
+		if query, err = r.policyEngine.ApplyPolicies(query, params, authCtx); err != nil {
+			return nil, err
+		}
+	}
+
+	count, err := executor.ExecuteCount(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// applyCursor prépare la pagination par curseur d'une lecture paginée. Avec le
+// paramètre cursor (vide pour la première page), le tri est complété par la clé
+// primaire pour être total et le curseur reçu est vérifié puis décodé en position
+// de reprise. Sans lui, le tri demandé n'est jamais modifié : un curseur n'est émis
+// pour une lecture limitée que si ce tri contient déjà la clé primaire.
+// Retourne true si un curseur pourra être émis pour la page suivante ; répond
+// 400 (PGRST100) si le curseur est invalide.
+func (r *Router) applyCursor(w http.ResponseWriter, dbName string, params *engine.QueryParameters) (bool, bool) {
+	if r.cursors == nil || (params.Limit == nil && !params.UseCursor) {
+		return false, true
+	}
+
+	var primaryKey []string
+	if schema, err := r.tableSchema(dbName, params.Table); err == nil {
+		for _, column := range schema.Columns {
+			if column.IsPrimaryKey {
+				primaryKey = append(primaryKey, column.Name)
+			}
+		}
+	}
+
+	if len(primaryKey) == 0 {
+		if params.UseCursor {
+			engine.WriteError(w, r.errors.QueryStringError(fmt.Errorf("cursor pagination requires a table with a primary key: %s", params.Table)))
+			return false, false
+		}
+		return false, true
+	}
+
+	keysetOrder := engine.KeysetOrder(params.Order, primaryKey)
+	if !params.UseCursor {
+		return len(keysetOrder) == len(params.Order), true
+	}
+	params.Order = keysetOrder
+
+	if params.Cursor != "" {
+		if params.Offset != nil && *params.Offset > 0 {
+			engine.WriteError(w, r.errors.QueryStringError(fmt.Errorf("cursor cannot be combined with offset")))
+			return false, false
+		}
+		after, err := r.cursors.Decode(params.Cursor, params.Table, params.Order)
+		if err != nil {
+			engine.WriteError(w, r.errors.QueryStringError(fmt.Errorf("invalid cursor: %w", err)))
+			return false, false
 		}
+		params.After = after
+	}
+
+	return true, true
+}
+
+// writeNextLink ajoute l'en-tête Link rel="next" d'une page pleine : même requête
+// avec le curseur de la dernière ligne, sans offset et avec la limite appliquée
+func (r *Router) writeNextLink(w http.ResponseWriter, req *http.Request, params *engine.QueryParameters, result *engine.QueryResult) {
+	if params.Limit == nil || *params.Limit == 0 || len(result.Rows) < *params.Limit {
+		return
+	}
+
+	next, ok := r.cursors.Encode(params.Table, params.Order, result.Rows[len(result.Rows)-1])
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	query.Del("offset")
+	query.Set("limit", strconv.Itoa(*params.Limit))
+	query.Set("cursor", next)
+	// Référence relative : valable quel que soit l'hôte ou le schéma par lequel
+	// le client joint le serveur
+	w.Header().Add("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", req.URL.Path, query.Encode()))
+}
+
+// writeContentRange ajoute les en-têtes Content-Range et Range-Unit d'une lecture
+// de table et retourne le statut de la réponse (200 ou 206) ; une plage qui
+// commence après la dernière ligne répond 416 (PGRST103)
+func (r *Router) writeContentRange(w http.ResponseWriter, params *engine.QueryParameters, rows int, total *int64) (int, bool) {
+	offset := 0
+	if params.Offset != nil {
+		offset = *params.Offset
+	}
+
+	w.Header().Set("Range-Unit", "items")
+	w.Header().Set("Content-Range", engine.ContentRange(offset, rows, total))
+
+	status := engine.RangeStatus(offset, rows, total)
+	if status == http.StatusRequestedRangeNotSatisfiable {
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeInvalidRange,
+			"Requested range not satisfiable",
+			fmt.Sprintf("An offset of %d was requested, but there are only %d rows.", offset, *total)))
+		return 0, false
 	}
+	return status, true
 }
 
 // negotiateContentType détermine le content type selon l'header Accept (PostgREST compatible)
@@ -330,75 +1095,110 @@ func (r *Router) negotiateContentType(acceptHeader string) string {
 	return "application/json"
 }
 
-// writeJSONResponse écrit une réponse JSON
-func (r *Router) writeJSONResponse(w http.ResponseWriter, result *engine.QueryResult) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+// checkGrant vérifie un droit sur une table et répond 403 en cas de refus
+func (r *Router) checkGrant(w http.ResponseWriter, authCtx *auth.AuthContext, privilege auth.Privilege, table string) bool {
+	if r.grants.Check(authCtx, privilege, table) {
+		return true
+	}
+
+	engine.WriteError(w, r.errors.PermissionError(string(privilege), table, authCtx.Role))
+	return false
+}
 
+// writeJSONResponse écrit une réponse JSON avec le statut donné (200 ou 206)
+func (r *Router) writeJSONResponse(w http.ResponseWriter, result *engine.QueryResult, status int) {
 	if len(result.Rows) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
 		w.Write([]byte("[]"))
 		return
 	}
 
-	jsonData, err := json.Marshal(result.Rows)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"JSON encoding failed: %s"}`, err.Error()), http.StatusInternalServerError)
-		return
+	// Les clés suivent l'ordre des colonnes du SELECT, comme en streaming
+	encoder := engine.NewRowEncoder(result.Columns)
+	values := make([]interface{}, len(result.Columns))
+	jsonData := []byte{'['}
+	for i, row := range result.Rows {
+		if i > 0 {
+			jsonData = append(jsonData, ',')
+		}
+		for j, column := range result.Columns {
+			values[j] = row[column]
+		}
+		var err error
+		if jsonData, err = encoder.AppendJSON(jsonData, values); err != nil {
+			engine.WriteError(w, r.errors.WrapError(err, "JSON encoding failed"))
+			return
+		}
 	}
+	jsonData = append(jsonData, ']')
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	w.Write(jsonData)
 }
 
-// writeCSVResponse écrit une réponse CSV
-func (r *Router) writeCSVResponse(w http.ResponseWriter, result *engine.QueryResult) {
+// writeCSVResponse écrit une réponse CSV avec le statut donné (200 ou 206)
+func (r *Router) writeCSVResponse(w http.ResponseWriter, result *engine.QueryResult, status int) {
 	w.Header().Set("Content-Type", "text/csv")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 
 	if len(result.Rows) == 0 {
 		w.Write([]byte(""))
 		return
 	}
 
+	out := csv.NewWriter(w)
+
 	// Écrire les en-têtes CSV
 	if len(result.Columns) > 0 {
-		headers := strings.Join(result.Columns, ",")
-		w.Write([]byte(headers + "\n"))
+		out.Write(result.Columns)
 	}
 
 	// Écrire les données
+	values := make([]interface{}, len(result.Columns))
 	for _, row := range result.Rows {
-		var values []string
-		for _, col := range result.Columns {
-			if val, exists := row[col]; exists {
-				values = append(values, fmt.Sprintf("%v", val))
-			} else {
-				values = append(values, "")
-			}
+		for i, col := range result.Columns {
+			values[i] = row[col]
 		}
-		w.Write([]byte(strings.Join(values, ",") + "\n"))
+		out.Write(csvRecord(values))
 	}
+	out.Flush()
 }
 
-// writeObjectResponse écrit une réponse single object (PostgREST compatible)
-func (r *Router) writeObjectResponse(w http.ResponseWriter, result *engine.QueryResult) {
-	w.Header().Set("Content-Type", "application/json")
-
-	if len(result.Rows) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`[]`))
-		return
+// csvRecord convertit une ligne en champs CSV dans l'ordre des colonnes ; NULL
+// devient un champ vide
+func csvRecord(values []interface{}) []string {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case json.RawMessage:
+			fields[i] = string(v)
+		default:
+			fields[i] = fmt.Sprintf("%v", v)
+		}
 	}
+	return fields
+}
 
-	if len(result.Rows) > 1 {
-		w.WriteHeader(http.StatusMultipleChoices)
+// writeObjectResponse écrit une réponse single object (PostgREST compatible) ;
+// zéro ou plusieurs lignes répondent 406 (PGRST116)
+func (r *Router) writeObjectResponse(w http.ResponseWriter, result *engine.QueryResult) {
+	if len(result.Rows) != 1 {
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeSingular,
+			"JSON object requested, multiple (or no) rows returned",
+			fmt.Sprintf("The result contains %d rows", len(result.Rows))))
+		return
 	}
 
 	jsonData, err := json.Marshal(result.Rows[0])
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"JSON encoding failed: %s"}`, err.Error()), http.StatusInternalServerError)
+		engine.WriteError(w, r.errors.WrapError(err, "JSON encoding failed"))
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(jsonData)
 }
 
@@ -415,7 +1215,7 @@ func (r *Router) writePlanResponse(w http.ResponseWriter, query string, args []i
 
 	jsonData, err := json.Marshal(plan)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"JSON encoding failed: %s"}`, err.Error()), http.StatusInternalServerError)
+		engine.WriteError(w, r.errors.WrapError(err, "JSON encoding failed"))
 		return
 	}
 
@@ -424,31 +1224,51 @@ func (r *Router) writePlanResponse(w http.ResponseWriter, query string, args []i
 
 func (r *Router) handleTableCreate(dbName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		// Authentifier la requête
+		authCtx, err := r.jwtManager.AuthenticateRequest(req)
+		if err != nil {
+			engine.WriteError(w, r.errors.AuthError(err.Error()))
+			return
+		}
+
 		// Parser le corps de la requête JSON
 		var data map[string]interface{}
 		if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Invalid JSON: %s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.BodyError(err))
 			return
 		}
 
 		// Obtenir la base de données
 		database, err := r.dbManager.GetDB(dbName)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Database %s not found"}`, dbName), http.StatusNotFound)
+			engine.WriteError(w, r.errors.NotFoundError("database "+dbName))
+			return
+		}
+		if database.Writer == nil {
+			engine.WriteError(w, r.errors.ReadOnlyError(dbName))
 			return
 		}
 
 		// Parser les paramètres pour obtenir le nom de la table
 		params, err := r.parser.ParseQuery(req.URL.Path, req.URL.Query())
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.QueryStringError(err))
+			return
+		}
+
+		if !r.checkGrant(w, authCtx, auth.PrivilegeInsert, params.Table) {
+			return
+		}
+
+		// Valider le corps contre le schéma avant l'insertion
+		if !r.validateBody(w, dbName, params.Table, data, true) {
 			return
 		}
 
 		// Construire la requête INSERT
 		query, args, err := r.builder.BuildInsert(params.Table, data)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.BodyError(err))
 			return
 		}
 
@@ -456,7 +1276,7 @@ func (r *Router) handleTableCreate(dbName string) http.HandlerFunc {
 		executor := engine.NewExecutor(database.Writer)
 		result, err := executor.ExecuteCommand(query, args)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Insert failed: %s"}`, err.Error()), http.StatusInternalServerError)
+			engine.WriteError(w, r.errors.DatabaseError("Insert", err))
 			return
 		}
 
@@ -483,39 +1303,68 @@ func (r *Router) handleTableCreate(dbName string) http.HandlerFunc {
 
 func (r *Router) handleTableUpdate(dbName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		// Authentifier la requête
+		authCtx, err := r.jwtManager.AuthenticateRequest(req)
+		if err != nil {
+			engine.WriteError(w, r.errors.AuthError(err.Error()))
+			return
+		}
+
 		// Parser le corps de la requête JSON
 		var data map[string]interface{}
 		if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Invalid JSON: %s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.BodyError(err))
 			return
 		}
 
 		// Obtenir la base de données
 		database, err := r.dbManager.GetDB(dbName)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Database %s not found"}`, dbName), http.StatusNotFound)
+			engine.WriteError(w, r.errors.NotFoundError("database "+dbName))
+			return
+		}
+		if database.Writer == nil {
+			engine.WriteError(w, r.errors.ReadOnlyError(dbName))
 			return
 		}
 
 		// Parser les paramètres pour obtenir le nom de la table et les filtres
 		params, err := r.parser.ParseQuery(req.URL.Path, req.URL.Query())
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.QueryStringError(err))
+			return
+		}
+
+		if !r.checkGrant(w, authCtx, auth.PrivilegeUpdate, params.Table) {
+			return
+		}
+
+		// Valider les colonnes modifiées contre le schéma
+		if !r.validateBody(w, dbName, params.Table, data, false) {
 			return
 		}
 
 		// Construire la requête UPDATE
 		query, args, err := r.builder.BuildUpdate(params.Table, data, params.Filters)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.BodyError(err))
 			return
 		}
 
+		// Appliquer les politiques de sécurité
+		if r.policyEngine != nil {
+			query, err = r.policyEngine.ApplyPolicies(query, params, authCtx)
+			if err != nil {
+				engine.WriteError(w, r.errors.WrapError(err, "Policy application failed"))
+				return
+			}
+		}
+
 		// Exécuter la requête
 		executor := engine.NewExecutor(database.Writer)
 		result, err := executor.ExecuteCommand(query, args)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Update failed: %s"}`, err.Error()), http.StatusInternalServerError)
+			engine.WriteError(w, r.errors.DatabaseError("Update", err))
 			return
 		}
 
@@ -542,32 +1391,56 @@ func (r *Router) handleTableUpdate(dbName string) http.HandlerFunc {
 
 func (r *Router) handleTableDelete(dbName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		// Authentifier la requête
+		authCtx, err := r.jwtManager.AuthenticateRequest(req)
+		if err != nil {
+			engine.WriteError(w, r.errors.AuthError(err.Error()))
+			return
+		}
+
 		// Obtenir la base de données
 		database, err := r.dbManager.GetDB(dbName)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Database %s not found"}`, dbName), http.StatusNotFound)
+			engine.WriteError(w, r.errors.NotFoundError("database "+dbName))
+			return
+		}
+		if database.Writer == nil {
+			engine.WriteError(w, r.errors.ReadOnlyError(dbName))
 			return
 		}
 
 		// Parser les paramètres pour obtenir le nom de la table et les filtres
 		params, err := r.parser.ParseQuery(req.URL.Path, req.URL.Query())
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.QueryStringError(err))
+			return
+		}
+
+		if !r.checkGrant(w, authCtx, auth.PrivilegeDelete, params.Table) {
 			return
 		}
 
 		// Construire la requête DELETE
 		query, args, err := r.builder.BuildDelete(params.Table, params.Filters)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			engine.WriteError(w, r.errors.QueryStringError(err))
 			return
 		}
 
+		// Appliquer les politiques de sécurité
+		if r.policyEngine != nil {
+			query, err = r.policyEngine.ApplyPolicies(query, params, authCtx)
+			if err != nil {
+				engine.WriteError(w, r.errors.WrapError(err, "Policy application failed"))
+				return
+			}
+		}
+
 		// Exécuter la requête
 		executor := engine.NewExecutor(database.Writer)
 		result, err := executor.ExecuteCommand(query, args)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Delete failed: %s"}`, err.Error()), http.StatusInternalServerError)
+			engine.WriteError(w, r.errors.DatabaseError("Delete", err))
 			return
 		}
 
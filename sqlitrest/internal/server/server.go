@@ -27,7 +27,10 @@ func Start() error {
 	defer dbManager.Close()
 
 	// Créer router
-	r := router.New(dbManager, cfg)
+	r, err := router.New(dbManager, cfg)
+	if err != nil {
+		return err
+	}
 
 	// Démarrer serveur HTTP
 	go func() {
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyPrefix = "sqlr_"
+	// Longueur de l'identifiant public affiché (préfixe inclus)
+	apiKeyDisplayLength = 12
+	// Intervalle minimal entre deux mises à jour de last_used_at
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKey représente une clé API de service (le secret n'est jamais stocké)
+type APIKey struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Role        string     `json:"role"`
+	TenantID    string     `json:"tenant_id,omitempty"`
+	Permissions []string   `json:"permissions,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Revoked     bool       `json:"revoked"`
+}
+
+// APIKeyManager gère les clés API stockées (hachées) dans la table _api_keys
+type APIKeyManager struct {
+	db *sql.DB
+}
+
+// NewAPIKeyManager crée un gestionnaire de clés API et la table _api_keys si besoin
+func NewAPIKeyManager(db *sql.DB) (*APIKeyManager, error) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS _api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		role TEXT NOT NULL,
+		tenant_id TEXT,
+		permissions TEXT NOT NULL DEFAULT '[]',
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		revoked_at DATETIME
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create api keys table: %w", err)
+	}
+
+	return &APIKeyManager{db: db}, nil
+}
+
+// Create génère une nouvelle clé API. La clé en clair n'est retournée qu'ici.
+func (m *APIKeyManager) Create(name, role, tenantID string, permissions []string, expiresAt *time.Time) (string, *APIKey, error) {
+	if name == "" || role == "" {
+		return "", nil, fmt.Errorf("api key name and role are required")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	if permissions == nil {
+		permissions = []string{}
+	}
+	permissionsJSON, err := json.Marshal(permissions)
+	if err != nil {
+		return "", nil, err
+	}
+
+	apiKey := &APIKey{
+		Name:        name,
+		Prefix:      key[:apiKeyDisplayLength],
+		Role:        role,
+		TenantID:    tenantID,
+		Permissions: permissions,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	result, err := m.db.Exec(
+		`INSERT INTO _api_keys (name, prefix, key_hash, role, tenant_id, permissions, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		apiKey.Name, apiKey.Prefix, hashToken(key), apiKey.Role, nullString(tenantID),
+		string(permissionsJSON), nullTime(expiresAt), apiKey.CreatedAt,
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to store api key: %w", err)
+	}
+
+	apiKey.ID, _ = result.LastInsertId()
+	return key, apiKey, nil
+}
+
+// List retourne toutes les clés API, révoquées comprises
+func (m *APIKeyManager) List() ([]APIKey, error) {
+	rows, err := m.db.Query(`SELECT id, name, prefix, role, tenant_id, permissions, expires_at, last_used_at, created_at, revoked_at
+		FROM _api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeByID révoque une clé API par identifiant
+func (m *APIKeyManager) RevokeByID(id int64) error {
+	result, err := m.db.Exec(
+		`UPDATE _api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no active api key with id %d", id)
+	}
+	return nil
+}
+
+// RevokeByName révoque la clé API active portant ce nom. Les noms n'étant pas
+// uniques, plusieurs clés actives homonymes sont refusées : révoquer par identifiant.
+func (m *APIKeyManager) RevokeByName(name string) error {
+	var active int
+	var id int64
+	err := m.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(MAX(id), 0) FROM _api_keys WHERE name = ? AND revoked_at IS NULL`, name,
+	).Scan(&active, &id)
+	if err != nil {
+		return fmt.Errorf("failed to look up api key: %w", err)
+	}
+
+	switch active {
+	case 0:
+		return fmt.Errorf("no active api key named %q", name)
+	case 1:
+		return m.RevokeByID(id)
+	}
+	return fmt.Errorf("%d active api keys are named %q, revoke by id", active, name)
+}
+
+// Authenticate résout une clé API en contexte d'authentification
+func (m *APIKeyManager) Authenticate(key string) (*AuthContext, error) {
+	row := m.db.QueryRow(`SELECT id, name, prefix, role, tenant_id, permissions, expires_at, last_used_at, created_at, revoked_at
+		FROM _api_keys WHERE key_hash = ?`, hashToken(key))
+
+	apiKey, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invalid api key")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if apiKey.Revoked {
+		return nil, fmt.Errorf("api key %s has been revoked", apiKey.Prefix)
+	}
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, fmt.Errorf("api key %s has expired", apiKey.Prefix)
+	}
+
+	// Limiter les écritures : last_used_at est mis à jour au plus une fois par minute
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		m.db.Exec("UPDATE _api_keys SET last_used_at = ? WHERE id = ?", now, apiKey.ID)
+	}
+
+	return &AuthContext{
+		Authenticated: true,
+		UserID:        fmt.Sprintf("apikey:%d", apiKey.ID),
+		Role:          apiKey.Role,
+		TenantID:      apiKey.TenantID,
+		Permissions:   apiKey.Permissions,
+		Claims: map[string]interface{}{
+			"api_key_id":   apiKey.ID,
+			"api_key_name": apiKey.Name,
+			"role":         apiKey.Role,
+		},
+		APIKey: apiKey.Prefix,
+	}, nil
+}
+
+// scanAPIKey lit une ligne de _api_keys
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	var key APIKey
+	var tenantID sql.NullString
+	var permissions string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &tenantID, &permissions,
+		&expiresAt, &lastUsedAt, &key.CreatedAt, &revokedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan api key: %w", err)
+	}
+
+	key.TenantID = tenantID.String
+	if err := json.Unmarshal([]byte(permissions), &key.Permissions); err != nil {
+		return nil, fmt.Errorf("invalid permissions for api key %d: %w", key.ID, err)
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	key.Revoked = revokedAt.Valid
+
+	return &key, nil
+}
+
+// ExtractAPIKeyFromRequest extrait une clé API de l'en-tête apikey
+// ou de "Authorization: ApiKey <clé>"
+func ExtractAPIKeyFromRequest(req *http.Request) string {
+	if key := req.Header.Get("apikey"); key != "" {
+		return key
+	}
+
+	authHeader := req.Header.Get("Authorization")
+	if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "ApiKey ") {
+		return strings.TrimSpace(authHeader[7:])
+	}
+
+	return ""
+}
+
+// hashToken hache une clé API ou un refresh token. Ces jetons étant aléatoires
+// sur 256 bits, un SHA-256 suffit et permet la recherche directe par empreinte.
+func hashToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
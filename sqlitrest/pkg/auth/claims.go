@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// claimPathSegment est un segment de chemin de claim : clé d'objet ou index de tableau
+type claimPathSegment struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// parseClaimPath parse un chemin de claim de style JSONPath, comme le
+// jwt-role-claim-key de PostgREST : .role, .realm_access.roles[0],
+// ."https://example.com/roles"[0] ou ['custom key']. Le préfixe $ est optionnel.
+func parseClaimPath(path string) ([]claimPathSegment, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	if path == "" {
+		return nil, fmt.Errorf("empty claim path")
+	}
+
+	var segments []claimPathSegment
+	i := 0
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			i++
+			if i < len(path) && path[i] == '"' {
+				key, next, err := readQuotedKey(path, i, '"')
+				if err != nil {
+					return nil, err
+				}
+				segments = append(segments, claimPathSegment{key: key})
+				i = next
+				continue
+			}
+			start := i
+			for i < len(path) && path[i] != '.' && path[i] != '[' {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("invalid claim path %q: empty key", path)
+			}
+			segments = append(segments, claimPathSegment{key: path[start:i]})
+
+		case '[':
+			i++
+			if i < len(path) && (path[i] == '\'' || path[i] == '"') {
+				key, next, err := readQuotedKey(path, i, path[i])
+				if err != nil {
+					return nil, err
+				}
+				if next >= len(path) || path[next] != ']' {
+					return nil, fmt.Errorf("invalid claim path %q: missing ]", path)
+				}
+				segments = append(segments, claimPathSegment{key: key})
+				i = next + 1
+				continue
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid claim path %q: missing ]", path)
+			}
+			index, err := strconv.Atoi(path[i : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid claim path %q: bad index %q", path, path[i:i+end])
+			}
+			segments = append(segments, claimPathSegment{index: index, isIdx: true})
+			i += end + 1
+
+		default:
+			// Autoriser "role" comme raccourci de ".role" en tête de chemin
+			if len(segments) > 0 {
+				return nil, fmt.Errorf("invalid claim path %q at position %d", path, i)
+			}
+			path = path[:i] + "." + path[i:]
+		}
+	}
+
+	return segments, nil
+}
+
+// readQuotedKey lit une clé entre guillemets à partir de path[start]
+func readQuotedKey(path string, start int, quote byte) (string, int, error) {
+	end := strings.IndexByte(path[start+1:], quote)
+	if end == -1 {
+		return "", 0, fmt.Errorf("invalid claim path %q: unterminated quote", path)
+	}
+	return path[start+1 : start+1+end], start + end + 2, nil
+}
+
+// LookupClaim retourne la valeur désignée par un chemin dans les claims bruts
+func LookupClaim(claims map[string]interface{}, path string) (interface{}, bool) {
+	segments, err := parseClaimPath(path)
+	if err != nil {
+		return nil, false
+	}
+	return lookupSegments(claims, segments)
+}
+
+func lookupSegments(claims map[string]interface{}, segments []claimPathSegment) (interface{}, bool) {
+	var current interface{} = claims
+
+	for _, segment := range segments {
+		if segment.isIdx {
+			list, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			index := segment.index
+			if index < 0 {
+				index += len(list)
+			}
+			if index < 0 || index >= len(list) {
+				return nil, false
+			}
+			current = list[index]
+			continue
+		}
+
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[segment.key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, current != nil
+}
+
+// claimString convertit une valeur de claim scalaire en chaîne
+func claimString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, v != ""
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// claimStrings convertit un claim en liste de chaînes (tableau, ou chaîne
+// séparée par des espaces comme le claim OAuth "scope")
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			if s, ok := claimString(item); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// decodeRawClaims décode le payload d'un token déjà vérifié en map de claims
+func decodeRawClaims(tokenString string) (map[string]interface{}, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	payload, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+
+	var raw map[string]interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+	return raw, nil
+}
+
+// decodeSegment décode un segment base64url de JWT (avec ou sans padding)
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+// Chemins des claims par défaut, ceux des tokens générés par le serveur
+const (
+	defaultUserIDClaimKey      = ".user_id"
+	defaultRoleClaimKey        = ".role"
+	defaultTenantClaimKey      = ".tenant_id"
+	defaultPermissionsClaimKey = ".permissions"
+)
+
+// applyClaimMapping renseigne les champs de Claims à partir des chemins configurés
+// (ou par défaut). Un claim absent ou de forme inattendue laisse le champ vide.
+// L'identifiant utilisateur retombe sur "sub" si aucun autre claim n'est trouvé.
+func (j *JWTManager) applyClaimMapping(claims *Claims, raw map[string]interface{}) {
+	claims.Raw = raw
+
+	if value, ok := LookupClaim(raw, claimKey(j.config.UserIDClaimKey, defaultUserIDClaimKey)); ok {
+		claims.UserID, _ = claimString(value)
+	}
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
+
+	if value, ok := LookupClaim(raw, claimKey(j.config.RoleClaimKey, defaultRoleClaimKey)); ok {
+		claims.Role, _ = claimString(value)
+	}
+
+	if value, ok := LookupClaim(raw, claimKey(j.config.TenantClaimKey, defaultTenantClaimKey)); ok {
+		claims.TenantID, _ = claimString(value)
+	}
+
+	if value, ok := LookupClaim(raw, claimKey(j.config.PermissionsClaimKey, defaultPermissionsClaimKey)); ok {
+		claims.Permissions = claimStrings(value)
+	}
+}
+
+// claimKey retourne le chemin configuré, ou le chemin par défaut
+func claimKey(configured, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	return fallback
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Privilege représente un droit sur une table, une vue ou une fonction RPC
+type Privilege string
+
+const (
+	PrivilegeSelect  Privilege = "SELECT"
+	PrivilegeInsert  Privilege = "INSERT"
+	PrivilegeUpdate  Privilege = "UPDATE"
+	PrivilegeDelete  Privilege = "DELETE"
+	PrivilegeExecute Privilege = "EXECUTE" // fonctions RPC
+	PrivilegeAll     Privilege = "ALL"
+)
+
+// GrantRule accorde des privilèges à un rôle sur des objets.
+// Les objets acceptent les motifs * et ? ; "*" ne couvre pas les tables internes (_*).
+type GrantRule struct {
+	Role       string   `toml:"role" json:"role"`
+	Privileges []string `toml:"privileges" json:"privileges"`
+	Objects    []string `toml:"objects" json:"objects"`
+	Source     string   `toml:"-" json:"source,omitempty"`
+}
+
+// GrantManager vérifie les droits des rôles (config + table _grants)
+type GrantManager struct {
+	db          *sql.DB
+	configRules []GrantRule
+	rules       []GrantRule
+	mutex       sync.RWMutex
+}
+
+// NewGrantManager crée un nouveau gestionnaire de droits
+func NewGrantManager(db *sql.DB, rules []GrantRule) *GrantManager {
+	return &GrantManager{
+		db:          db,
+		configRules: rules,
+	}
+}
+
+// LoadGrants charge les droits de la configuration et de la table _grants
+func (g *GrantManager) LoadGrants() error {
+	var rules []GrantRule
+	for _, rule := range g.configRules {
+		if err := validateGrantRule(rule); err != nil {
+			return err
+		}
+		rule.Source = "config"
+		rules = append(rules, rule)
+	}
+
+	if g.db != nil {
+		tableRules, err := g.loadTableGrants()
+		if err != nil {
+			return err
+		}
+		rules = append(rules, tableRules...)
+	}
+
+	g.mutex.Lock()
+	g.rules = rules
+	g.mutex.Unlock()
+	return nil
+}
+
+// loadTableGrants charge les droits actifs de la table _grants
+func (g *GrantManager) loadTableGrants() ([]GrantRule, error) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS _grants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		role TEXT NOT NULL,
+		privilege TEXT NOT NULL CHECK (privilege IN ('SELECT', 'INSERT', 'UPDATE', 'DELETE', 'EXECUTE', 'ALL')),
+		object TEXT NOT NULL,
+		enabled BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := g.db.Exec(createTableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create grants table: %w", err)
+	}
+
+	rows, err := g.db.Query("SELECT role, privilege, object FROM _grants WHERE enabled = TRUE")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load grants: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []GrantRule
+	for rows.Next() {
+		var role, privilege, object string
+		if err := rows.Scan(&role, &privilege, &object); err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+
+		rules = append(rules, GrantRule{
+			Role:       role,
+			Privileges: []string{privilege},
+			Objects:    []string{object},
+			Source:     "_grants",
+		})
+	}
+
+	return rules, rows.Err()
+}
+
+// validateGrantRule vérifie qu'une règle est complète
+func validateGrantRule(rule GrantRule) error {
+	if rule.Role == "" {
+		return fmt.Errorf("grant rule: role is required")
+	}
+	if len(rule.Privileges) == 0 || len(rule.Objects) == 0 {
+		return fmt.Errorf("grant rule for %s: privileges and objects are required", rule.Role)
+	}
+
+	for _, p := range rule.Privileges {
+		switch Privilege(strings.ToUpper(p)) {
+		case PrivilegeSelect, PrivilegeInsert, PrivilegeUpdate, PrivilegeDelete, PrivilegeExecute, PrivilegeAll:
+		default:
+			return fmt.Errorf("grant rule for %s: invalid privilege %q", rule.Role, p)
+		}
+	}
+	for _, o := range rule.Objects {
+		if _, err := path.Match(o, ""); err != nil {
+			return fmt.Errorf("grant rule for %s: invalid object pattern %q", rule.Role, o)
+		}
+	}
+
+	return nil
+}
+
+// Rules retourne une copie des règles actives
+func (g *GrantManager) Rules() []GrantRule {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return append([]GrantRule(nil), g.rules...)
+}
+
+// Check vérifie qu'un contexte d'authentification possède un privilège sur un objet.
+// Les règles du rôle (ou du rôle "*"), celles du rôle anonyme pour les requêtes
+// non authentifiées et les permissions du token ("read:<table>", "write:<table>",
+// "execute:<fonction>", "<action>:all") sont prises en compte.
+func (g *GrantManager) Check(authCtx *AuthContext, privilege Privilege, object string) bool {
+	g.mutex.RLock()
+	for _, rule := range g.rules {
+		if (rule.Role == authCtx.Role || rule.Role == "*") && ruleAllows(rule, privilege, object) {
+			g.mutex.RUnlock()
+			return true
+		}
+	}
+	g.mutex.RUnlock()
+
+	if !authCtx.Authenticated {
+		return false
+	}
+
+	for _, permission := range authCtx.Permissions {
+		if permissionAllows(permission, privilege, object) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleAllows vérifie si une règle couvre le privilège et l'objet
+func ruleAllows(rule GrantRule, privilege Privilege, object string) bool {
+	privilegeMatch := false
+	for _, p := range rule.Privileges {
+		p := Privilege(strings.ToUpper(p))
+		if p == privilege || p == PrivilegeAll {
+			privilegeMatch = true
+			break
+		}
+	}
+	if !privilegeMatch {
+		return false
+	}
+
+	for _, pattern := range rule.Objects {
+		if matchObject(pattern, object) {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionAllows interprète une permission du token comme un droit
+func permissionAllows(permission string, privilege Privilege, object string) bool {
+	action, target, found := strings.Cut(permission, ":")
+	if !found {
+		return false
+	}
+	if target == "all" {
+		target = "*"
+	}
+
+	switch action {
+	case "read":
+		return privilege == PrivilegeSelect && matchObject(target, object)
+	case "write":
+		return (privilege == PrivilegeInsert || privilege == PrivilegeUpdate || privilege == PrivilegeDelete) && matchObject(target, object)
+	case "execute":
+		return privilege == PrivilegeExecute && matchObject(target, object)
+	}
+	return false
+}
+
+// matchObject compare un objet à un motif ; les objets internes (_*) ne sont
+// couverts que par un motif commençant lui-même par "_"
+func matchObject(pattern, object string) bool {
+	if strings.HasPrefix(object, "_") && !strings.HasPrefix(pattern, "_") {
+		return false
+	}
+	matched, err := path.Match(pattern, object)
+	return err == nil && matched
+}
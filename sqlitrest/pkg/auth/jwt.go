@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"context"
+	"crypto"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -9,12 +12,19 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Claims représente les claims JWT
+// Claims représente les claims JWT. À la validation, seuls les claims enregistrés
+// sont décodés avec leur type ; les autres champs sont lus dans les claims bruts
+// selon la correspondance configurée (voir applyClaimMapping), quelle que soit la
+// forme des claims homonymes du fournisseur.
 type Claims struct {
 	UserID      string   `json:"user_id"`
 	Role        string   `json:"role"`
 	TenantID    string   `json:"tenant_id,omitempty"`
 	Permissions []string `json:"permissions,omitempty"`
+	// Partie autorisée (OIDC) : client à qui le token a été délivré
+	AuthorizedParty string `json:"azp,omitempty"`
+	// Tous les claims du token, tels que reçus
+	Raw map[string]interface{} `json:"-"`
 	jwt.RegisteredClaims
 }
 
@@ -25,8 +35,36 @@ type JWTConfig struct {
 	Secret    string   `toml:"secret"`
 	Issuer    string   `toml:"issuer"`
 	Audience  []string `toml:"audience"`
+
+	// Clients autorisés : si renseigné, le claim azp doit en faire partie
+	AuthorizedParties []string `toml:"authorized_parties"`
+
+	// Clés asymétriques (RS*, PS*, ES*, EdDSA)
+	Algorithms      []string `toml:"algorithms"`       // algorithmes acceptés (défaut: [algorithm])
+	PublicKeyFiles  []string `toml:"public_key_files"` // clés publiques PEM de vérification
+	JWKSFile        string   `toml:"jwks_file"`        // jeu de clés JWKS local
+	PrivateKeyFile  string   `toml:"private_key_file"` // clé privée PEM pour signer (optionnelle)
+	KeyID           string   `toml:"key_id"`           // kid des tokens générés
+	RefreshInterval string   `toml:"refresh_interval"` // relecture des clés pour la rotation (défaut: 5m)
+
+	// Fournisseur d'identité distant
+	IssuerURL string `toml:"issuer_url"` // découverte OIDC (/.well-known/openid-configuration)
+	JWKSURL   string `toml:"jwks_url"`   // JWKS distant, sans découverte
+
+	// Correspondance des claims (chemins de style JSONPath, ex: .realm_access.roles[0])
+	RoleClaimKey        string `toml:"role_claim_key"`        // défaut: .role
+	UserIDClaimKey      string `toml:"user_id_claim_key"`     // défaut: .user_id, puis .sub
+	TenantClaimKey      string `toml:"tenant_claim_key"`      // défaut: .tenant_id
+	PermissionsClaimKey string `toml:"permissions_claim_key"` // défaut: .permissions
+
+	// Rôle attribué aux requêtes sans token (défaut: anonymous)
+	AnonymousRole string `toml:"anonymous_role"`
 }
 
+const defaultAnonymousRole = "anonymous"
+
+const defaultKeyRefreshInterval = 5 * time.Minute
+
 // AuthContext contient le contexte d'authentification
 type AuthContext struct {
 	Authenticated bool
@@ -34,13 +72,22 @@ type AuthContext struct {
 	Role          string
 	TenantID      string
 	Permissions   []string
+	Claims        map[string]interface{} // claims bruts du token
 	Token         string
+	APIKey        string // préfixe de la clé API utilisée, vide pour un JWT
 }
 
 // JWTManager gère les tokens JWT
 type JWTManager struct {
-	config JWTConfig
-	secret []byte
+	config     JWTConfig
+	secret     []byte
+	signingKey crypto.PrivateKey
+	keys       *KeySet
+	remoteKeys *RemoteKeySet
+	apiKeys    *APIKeyManager
+	revoked    *RevocationStore
+	algorithms []string
+	stopChan   chan struct{}
 }
 
 // NewJWTManager crée un nouveau gestionnaire JWT
@@ -49,22 +96,142 @@ func NewJWTManager(config JWTConfig) (*JWTManager, error) {
 		return &JWTManager{config: config}, nil
 	}
 
-	if config.Secret == "" {
-		return nil, fmt.Errorf("JWT secret is required when JWT is enabled")
+	if config.Algorithm == "" {
+		config.Algorithm = "HS256"
 	}
 
-	return &JWTManager{
-		config: config,
-		secret: []byte(config.Secret),
-	}, nil
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{config.Algorithm}
+	}
+
+	j := &JWTManager{
+		config:     config,
+		keys:       NewKeySet(),
+		algorithms: algorithms,
+	}
+
+	for _, path := range []string{config.RoleClaimKey, config.UserIDClaimKey, config.TenantClaimKey, config.PermissionsClaimKey} {
+		if path == "" {
+			continue
+		}
+		if _, err := parseClaimPath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	needsSecret, needsKeys := false, false
+	for _, alg := range algorithms {
+		method := j.GetSigningMethod(alg)
+		if method == nil {
+			return nil, fmt.Errorf("unsupported JWT algorithm: %s", alg)
+		}
+		if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+			needsSecret = true
+		} else {
+			needsKeys = true
+		}
+	}
+
+	if needsSecret {
+		if config.Secret == "" {
+			return nil, fmt.Errorf("JWT secret is required when JWT is enabled")
+		}
+		j.secret = []byte(config.Secret)
+	}
+
+	if config.IssuerURL != "" || config.JWKSURL != "" {
+		// L'émetteur découvert fait foi si aucun émetteur n'est configuré
+		if j.config.Issuer == "" && config.IssuerURL != "" {
+			j.config.Issuer = strings.TrimRight(config.IssuerURL, "/")
+		}
+		j.remoteKeys = NewRemoteKeySet(config.IssuerURL, config.JWKSURL)
+		if err := j.remoteKeys.ensureFresh(""); err != nil {
+			log.Printf("Remote JWKS not available yet, will retry on demand: %v", err)
+		}
+	}
+
+	if needsKeys {
+		keys, err := loadLocalKeys(config)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 && j.remoteKeys == nil {
+			return nil, fmt.Errorf("public_key_files, jwks_file, jwks_url or issuer_url is required for asymmetric JWT algorithms")
+		}
+		if len(keys) > 0 {
+			j.keys.replace(keys)
+			j.startKeyRefresh()
+		}
+	}
+
+	if config.PrivateKeyFile != "" {
+		key, err := loadPEMPrivateKey(config.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		j.signingKey = key
+	}
+
+	return j, nil
 }
 
-// GenerateToken génère un token JWT
+// startKeyRefresh relit périodiquement les clés locales pour suivre leur rotation
+func (j *JWTManager) startKeyRefresh() {
+	interval := defaultKeyRefreshInterval
+	if j.config.RefreshInterval != "" {
+		if d, err := time.ParseDuration(j.config.RefreshInterval); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("Invalid JWT refresh_interval %q, using %s", j.config.RefreshInterval, interval)
+		}
+	}
+
+	j.stopChan = make(chan struct{})
+	go func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				keys, err := loadLocalKeys(j.config)
+				if err != nil {
+					log.Printf("Failed to reload JWT keys, keeping previous keys: %v", err)
+					continue
+				}
+				j.keys.replace(keys)
+			}
+		}
+	}(j.stopChan)
+}
+
+// Close arrête le rafraîchissement des clés
+func (j *JWTManager) Close() {
+	if j.stopChan != nil {
+		close(j.stopChan)
+		j.stopChan = nil
+	}
+}
+
+// GenerateToken génère un token JWT valable 24h
 func (j *JWTManager) GenerateToken(userID, role, tenantID string, permissions []string) (string, error) {
+	return j.GenerateTokenWithTTL(userID, role, tenantID, permissions, 24*time.Hour)
+}
+
+// GenerateTokenWithTTL génère un token JWT avec une durée de validité donnée
+func (j *JWTManager) GenerateTokenWithTTL(userID, role, tenantID string, permissions []string, ttl time.Duration) (string, error) {
 	if !j.config.Enabled {
 		return "", fmt.Errorf("JWT is disabled")
 	}
 
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
 	now := time.Now()
 	claims := Claims{
 		UserID:      userID,
@@ -75,14 +242,50 @@ func (j *JWTManager) GenerateToken(userID, role, tenantID string, permissions []
 			Issuer:    j.config.Issuer,
 			Subject:   userID,
 			Audience:  j.config.Audience,
-			ExpiresAt: jwt.NewNumericDate(now.Add(24 * time.Hour)), // 24h
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
+			ID:        jti,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.GetSigningMethod(j.config.Algorithm), claims)
-	return token.SignedString(j.secret)
+	if err := j.CanSign(); err != nil {
+		return "", err
+	}
+	method := j.GetSigningMethod(j.config.Algorithm)
+
+	token := jwt.NewWithClaims(method, claims)
+	if j.config.KeyID != "" {
+		token.Header["kid"] = j.config.KeyID
+	}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		return token.SignedString(j.secret)
+	}
+	return token.SignedString(j.signingKey)
+}
+
+// CanSign vérifie que des tokens peuvent être émis : JWT activé et clé de
+// signature disponible pour l'algorithme configuré
+func (j *JWTManager) CanSign() error {
+	if !j.config.Enabled {
+		return fmt.Errorf("JWT is disabled")
+	}
+
+	method := j.GetSigningMethod(j.config.Algorithm)
+	if method == nil {
+		return fmt.Errorf("unsupported JWT algorithm: %s", j.config.Algorithm)
+	}
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if len(j.secret) == 0 {
+			return fmt.Errorf("JWT secret is required to sign %s tokens", j.config.Algorithm)
+		}
+		return nil
+	}
+	if j.signingKey == nil {
+		return fmt.Errorf("private_key_file is required to sign %s tokens", j.config.Algorithm)
+	}
+	return nil
 }
 
 // ValidateToken valide un token JWT et retourne les claims
@@ -96,26 +299,83 @@ func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 		tokenString = tokenString[7:]
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Valider l'algorithme
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, j.keyFunc, jwt.WithValidMethods(j.algorithms))
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	if registered, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
+		raw, err := decodeRawClaims(tokenString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token: %w", err)
+		}
+
+		claims := &Claims{RegisteredClaims: *registered}
+		claims.AuthorizedParty, _ = claimString(raw["azp"])
+		if err := j.validateClaims(claims); err != nil {
+			return nil, fmt.Errorf("invalid token: %w", err)
+		}
+		j.applyClaimMapping(claims, raw)
+
 		return claims, nil
 	}
 
 	return nil, fmt.Errorf("invalid token claims")
 }
 
-// GetSigningMethod retourne la méthode de signature
+// keyFunc sélectionne la clé de vérification selon l'algorithme et le kid du token
+func (j *JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	// Valider l'algorithme (le type de clé doit correspondre pour éviter toute confusion)
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(j.secret) == 0 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return j.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := j.keys.Lookup(kid, token.Method)
+	if err != nil && j.remoteKeys != nil {
+		return j.remoteKeys.Lookup(kid, token.Method)
+	}
+	return key, err
+}
+
+// validateClaims vérifie l'émetteur, l'audience et la partie autorisée
+func (j *JWTManager) validateClaims(claims *Claims) error {
+	if j.config.Issuer != "" && strings.TrimRight(claims.Issuer, "/") != strings.TrimRight(j.config.Issuer, "/") {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+
+	if len(j.config.Audience) > 0 {
+		if !containsAny(claims.Audience, j.config.Audience) {
+			return fmt.Errorf("token audience %v does not match %v", []string(claims.Audience), j.config.Audience)
+		}
+	}
+
+	// azp désigne le client à qui le token a été délivré (OIDC Core 3.1.3.7) :
+	// c'est un client ID, distinct de l'audience de l'API
+	if len(j.config.AuthorizedParties) > 0 && !containsAny([]string{claims.AuthorizedParty}, j.config.AuthorizedParties) {
+		return fmt.Errorf("unexpected authorized party %q", claims.AuthorizedParty)
+	}
+
+	return nil
+}
+
+// containsAny indique si au moins une valeur de values figure dans allowed
+func containsAny(values, allowed []string) bool {
+	for _, v := range values {
+		for _, a := range allowed {
+			if v == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetSigningMethod retourne la méthode de signature (nil si l'algorithme n'est pas supporté)
 func (j *JWTManager) GetSigningMethod(algorithm string) jwt.SigningMethod {
 	switch algorithm {
 	case "HS256":
@@ -124,8 +384,22 @@ func (j *JWTManager) GetSigningMethod(algorithm string) jwt.SigningMethod {
 		return jwt.SigningMethodHS384
 	case "HS512":
 		return jwt.SigningMethodHS512
+	case "RS256":
+		return jwt.SigningMethodRS256
+	case "RS384":
+		return jwt.SigningMethodRS384
+	case "RS512":
+		return jwt.SigningMethodRS512
+	case "ES256":
+		return jwt.SigningMethodES256
+	case "ES384":
+		return jwt.SigningMethodES384
+	case "PS256":
+		return jwt.SigningMethodPS256
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA
 	default:
-		return jwt.SigningMethodHS256
+		return nil
 	}
 }
 
@@ -152,20 +426,62 @@ func ExtractTokenFromRequest(req *http.Request) string {
 	return ""
 }
 
-// AuthenticateRequest authentifie une requête HTTP
+// authContextKey est la clé du contexte d'authentification dans context.Context
+type authContextKey struct{}
+
+// NewContext attache un contexte d'authentification déjà résolu à un contexte
+func NewContext(ctx context.Context, authCtx *AuthContext) context.Context {
+	return context.WithValue(ctx, authContextKey{}, authCtx)
+}
+
+// FromContext retourne le contexte d'authentification attaché, s'il existe
+func FromContext(ctx context.Context) (*AuthContext, bool) {
+	authCtx, ok := ctx.Value(authContextKey{}).(*AuthContext)
+	return authCtx, ok
+}
+
+// SetAPIKeyManager active l'authentification par clé API
+func (j *JWTManager) SetAPIKeyManager(apiKeys *APIKeyManager) {
+	j.apiKeys = apiKeys
+}
+
+// SetRevocationStore active la vérification des tokens révoqués
+func (j *JWTManager) SetRevocationStore(store *RevocationStore) {
+	j.revoked = store
+}
+
+// AuthenticateRequest authentifie une requête HTTP (clé API ou JWT)
 func (j *JWTManager) AuthenticateRequest(req *http.Request) (*AuthContext, error) {
+	// Déjà authentifiée par un middleware
+	if authCtx, ok := FromContext(req.Context()); ok {
+		return authCtx, nil
+	}
+
+	if j.apiKeys != nil {
+		if key := ExtractAPIKeyFromRequest(req); key != "" {
+			authCtx, err := j.apiKeys.Authenticate(key)
+			if err != nil {
+				return j.anonymousContext(), err
+			}
+			return authCtx, nil
+		}
+	}
+
 	if !j.config.Enabled {
-		return &AuthContext{Authenticated: false}, nil
+		return j.anonymousContext(), nil
 	}
 
 	tokenString := ExtractTokenFromRequest(req)
 	if tokenString == "" {
-		return &AuthContext{Authenticated: false}, nil
+		return j.anonymousContext(), nil
 	}
 
 	claims, err := j.ValidateToken(tokenString)
 	if err != nil {
-		return &AuthContext{Authenticated: false}, err
+		return j.anonymousContext(), err
+	}
+	if j.revoked != nil && j.revoked.IsRevoked(claims) {
+		return j.anonymousContext(), fmt.Errorf("token has been revoked")
 	}
 
 	return &AuthContext{
@@ -174,10 +490,20 @@ func (j *JWTManager) AuthenticateRequest(req *http.Request) (*AuthContext, error
 		Role:          claims.Role,
 		TenantID:      claims.TenantID,
 		Permissions:   claims.Permissions,
+		Claims:        claims.Raw,
 		Token:         tokenString,
 	}, nil
 }
 
+// anonymousContext retourne le contexte des requêtes non authentifiées
+func (j *JWTManager) anonymousContext() *AuthContext {
+	role := j.config.AnonymousRole
+	if role == "" {
+		role = defaultAnonymousRole
+	}
+	return &AuthContext{Authenticated: false, Role: role}
+}
+
 // HasPermission vérifie si l'utilisateur a une permission spécifique
 func (a *AuthContext) HasPermission(permission string) bool {
 	if !a.Authenticated {
@@ -202,26 +528,3 @@ func (a *AuthContext) IsOwner(resourceID string) bool {
 
 	return a.UserID == resourceID || a.Role == "admin"
 }
-
-// CanAccessTable vérifie si l'utilisateur peut accéder à une table
-func (a *AuthContext) CanAccessTable(tableName string) bool {
-	if !a.Authenticated {
-		// Tables publiques accessibles sans auth
-		publicTables := []string{"public_posts", "products", "categories"}
-		for _, table := range publicTables {
-			if table == tableName {
-				return true
-			}
-		}
-		return false
-	}
-
-	// Les admins peuvent tout accéder
-	if a.Role == "admin" {
-		return true
-	}
-
-	// Vérifier les permissions spécifiques
-	permission := fmt.Sprintf("read:%s", tableName)
-	return a.HasPermission(permission)
-}
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK représente une clé publique JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// jwksDocument est le document JWKS ({"keys": [...]})
+type jwksDocument struct {
+	Keys []JWK `json:"keys"`
+}
+
+// verificationKey est une clé publique utilisable pour vérifier un token
+type verificationKey struct {
+	kid string
+	alg string // algorithme imposé par la clé, vide si libre
+	key crypto.PublicKey
+}
+
+// KeySet est un jeu de clés publiques de vérification, remplaçable à chaud
+type KeySet struct {
+	keys  []verificationKey
+	mutex sync.RWMutex
+}
+
+// NewKeySet crée un jeu de clés vide
+func NewKeySet() *KeySet {
+	return &KeySet{}
+}
+
+// replace remplace atomiquement les clés du jeu
+func (ks *KeySet) replace(keys []verificationKey) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	ks.keys = keys
+}
+
+// Len retourne le nombre de clés du jeu
+func (ks *KeySet) Len() int {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	return len(ks.keys)
+}
+
+// HasKey indique si une clé avec ce kid est présente
+func (ks *KeySet) HasKey(kid string) bool {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	for _, k := range ks.keys {
+		if k.kid == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup retourne la ou les clés compatibles avec un token.
+// Avec un kid, les clés d'un autre kid sont écartées ; les clés sans kid
+// (fichiers PEM) restent candidates pour tout token. Sans kid, toutes les
+// clés compatibles avec l'algorithme sont essayées.
+func (ks *KeySet) Lookup(kid string, method jwt.SigningMethod) (interface{}, error) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	var candidates []jwt.VerificationKey
+	for _, k := range ks.keys {
+		if kid != "" && k.kid != "" && k.kid != kid {
+			continue
+		}
+		if k.alg != "" && k.alg != method.Alg() {
+			continue
+		}
+		if !keyMatchesMethod(k.key, method) {
+			continue
+		}
+		candidates = append(candidates, k.key)
+	}
+
+	switch len(candidates) {
+	case 0:
+		if kid != "" {
+			return nil, fmt.Errorf("no key found for kid %q and algorithm %s", kid, method.Alg())
+		}
+		return nil, fmt.Errorf("no key found for algorithm %s", method.Alg())
+	case 1:
+		return candidates[0], nil
+	default:
+		return jwt.VerificationKeySet{Keys: candidates}, nil
+	}
+}
+
+// keyMatchesMethod vérifie que le type de clé correspond à l'algorithme
+func keyMatchesMethod(key crypto.PublicKey, method jwt.SigningMethod) bool {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		return ok && ecKey.Curve.Params().BitSize == m.CurveBits
+	case *jwt.SigningMethodEd25519:
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
+
+// parseJWKS parse un document JWKS. Les clés non supportées ou réservées
+// au chiffrement (use=enc) sont ignorées.
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	var doc jwksDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	var keys []verificationKey
+	for i, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK #%d (kid %q): %w", i, jwk.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+
+		keys = append(keys, verificationKey{kid: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+
+	return keys, nil
+}
+
+// PublicKey convertit une JWK en clé publique Go (nil si le type n'est pas supporté)
+func (jwk JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBase64URL(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBase64URL(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBase64URL(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return key, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBase64URL(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, nil
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// loadPEMPublicKeys charge les clés publiques d'un fichier PEM
+// (PUBLIC KEY, RSA PUBLIC KEY ou CERTIFICATE, plusieurs blocs possibles)
+func loadPEMPublicKeys(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key %s: %w", path, err)
+	}
+
+	var keys []verificationKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var key crypto.PublicKey
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key in %s: %w", path, err)
+		}
+
+		keys = append(keys, verificationKey{key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found in %s", path)
+	}
+	return keys, nil
+}
+
+// loadPEMPrivateKey charge une clé privée de signature (PKCS#8, PKCS#1 ou EC)
+func loadPEMPrivateKey(path string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	return nil, fmt.Errorf("unsupported private key type %q in %s", block.Type, path)
+}
+
+// loadLocalKeys charge toutes les clés locales configurées (PEM et JWKS)
+func loadLocalKeys(config JWTConfig) ([]verificationKey, error) {
+	var keys []verificationKey
+
+	for _, path := range config.PublicKeyFiles {
+		pemKeys, err := loadPEMPublicKeys(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pemKeys...)
+	}
+
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file %s: %w", config.JWKSFile, err)
+		}
+		jwksKeys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", config.JWKSFile, err)
+		}
+		keys = append(keys, jwksKeys...)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Bornes de mise en cache du JWKS distant
+	minJWKSCacheDuration     = 30 * time.Second
+	defaultJWKSCacheDuration = time.Hour
+	maxJWKSCacheDuration     = 24 * time.Hour
+
+	// Délai minimal entre deux rafraîchissements forcés (kid inconnu)
+	minJWKSForcedRefresh = 30 * time.Second
+
+	remoteFetchTimeout = 10 * time.Second
+	maxRemoteDocSize   = 1 << 20
+)
+
+// oidcDiscovery contient les champs utiles de /.well-known/openid-configuration
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// RemoteKeySet récupère et met en cache un JWKS distant, découvert via OIDC
+// ou configuré directement
+type RemoteKeySet struct {
+	issuerURL string
+	jwksURL   string
+	client    *http.Client
+	keys      *KeySet
+
+	mutex       sync.Mutex // sérialise les téléchargements
+	expiresAt   time.Time
+	lastFetch   time.Time
+	lastFailure error
+}
+
+// NewRemoteKeySet crée un jeu de clés distant. issuerURL déclenche la découverte
+// OIDC ; jwksURL, s'il est fourni, est utilisé directement.
+func NewRemoteKeySet(issuerURL, jwksURL string) *RemoteKeySet {
+	return &RemoteKeySet{
+		issuerURL: strings.TrimRight(issuerURL, "/"),
+		jwksURL:   jwksURL,
+		client:    &http.Client{Timeout: remoteFetchTimeout},
+		keys:      NewKeySet(),
+	}
+}
+
+// Lookup retourne les clés compatibles, en rafraîchissant le cache s'il a expiré
+// ou si le kid demandé est inconnu
+func (r *RemoteKeySet) Lookup(kid string, method jwt.SigningMethod) (interface{}, error) {
+	if err := r.ensureFresh(kid); err != nil && r.keys.Len() == 0 {
+		return nil, err
+	}
+	return r.keys.Lookup(kid, method)
+}
+
+// ensureFresh télécharge le JWKS si le cache est expiré ou si le kid est inconnu
+func (r *RemoteKeySet) ensureFresh(kid string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	expired := now.After(r.expiresAt)
+	unknownKid := kid != "" && !r.keys.HasKey(kid) && now.Sub(r.lastFetch) >= minJWKSForcedRefresh
+
+	if !expired && !unknownKid {
+		return nil
+	}
+	// Éviter de marteler le fournisseur après un échec
+	if r.lastFailure != nil && now.Sub(r.lastFetch) < minJWKSForcedRefresh {
+		return r.lastFailure
+	}
+
+	r.lastFetch = now
+	if err := r.refresh(); err != nil {
+		r.lastFailure = err
+		log.Printf("Failed to refresh remote JWKS, keeping %d cached keys: %v", r.keys.Len(), err)
+		return err
+	}
+	r.lastFailure = nil
+	return nil
+}
+
+// refresh effectue la découverte (si nécessaire) puis télécharge le JWKS
+func (r *RemoteKeySet) refresh() error {
+	if r.jwksURL == "" {
+		discovery, err := r.discover()
+		if err != nil {
+			return err
+		}
+		r.jwksURL = discovery.JWKSURI
+	}
+
+	data, header, err := r.fetch(r.jwksURL)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.jwksURL, err)
+	}
+
+	r.keys.replace(keys)
+	r.expiresAt = time.Now().Add(cacheDuration(header))
+	return nil
+}
+
+// discover récupère le document de découverte OIDC de l'émetteur
+func (r *RemoteKeySet) discover() (*oidcDiscovery, error) {
+	url := r.issuerURL + "/.well-known/openid-configuration"
+	data, _, err := r.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery oidcDiscovery
+	if err := json.Unmarshal(data, &discovery); err != nil {
+		return nil, fmt.Errorf("invalid OIDC discovery document: %w", err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != r.issuerURL {
+		return nil, fmt.Errorf("OIDC issuer mismatch: expected %s, got %s", r.issuerURL, discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document has no jwks_uri")
+	}
+
+	return &discovery, nil
+}
+
+// fetch télécharge un document JSON
+func (r *RemoteKeySet) fetch(url string) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch %s: HTTP %d", url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteDocSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	return data, resp.Header, nil
+}
+
+// cacheDuration déduit la durée de cache des en-têtes Cache-Control / Expires
+func cacheDuration(header http.Header) time.Duration {
+	duration := defaultJWKSCacheDuration
+
+	if cc := header.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.TrimSpace(strings.ToLower(directive))
+			switch {
+			case directive == "no-store" || directive == "no-cache":
+				return minJWKSCacheDuration
+			case strings.HasPrefix(directive, "max-age="):
+				if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+					duration = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	} else if expires := header.Get("Expires"); expires != "" {
+		if t, err := http.ParseTime(expires); err == nil {
+			duration = time.Until(t)
+		}
+	}
+
+	if duration < minJWKSCacheDuration {
+		return minJWKSCacheDuration
+	}
+	if duration > maxJWKSCacheDuration {
+		return maxJWKSCacheDuration
+	}
+	return duration
+}
@@ -0,0 +1,318 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIssuer est un fournisseur OIDC minimal : document de découverte et JWKS
+type testIssuer struct {
+	server *httptest.Server
+
+	mutex        sync.Mutex
+	keys         map[string]*rsa.PrivateKey
+	issuer       string // issuer annoncé par la découverte (défaut: URL du serveur)
+	cacheControl string
+	jwksFetches  int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{keys: make(map[string]*rsa.PrivateKey)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mutex.Lock()
+		advertised := issuer.issuer
+		issuer.mutex.Unlock()
+		if advertised == "" {
+			advertised = issuer.server.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   advertised,
+			"jwks_uri": issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.mutex.Lock()
+		defer issuer.mutex.Unlock()
+		issuer.jwksFetches++
+
+		set := jwksDocument{}
+		for kid, key := range issuer.keys {
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Alg: "RS256",
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		if issuer.cacheControl != "" {
+			w.Header().Set("Cache-Control", issuer.cacheControl)
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// addKey génère une clé RSA publiée dans le JWKS sous le kid donné
+func (i *testIssuer) addKey(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	i.mutex.Lock()
+	i.keys[kid] = key
+	i.mutex.Unlock()
+}
+
+func (i *testIssuer) fetches() int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.jwksFetches
+}
+
+// sign émet un token RS256 signé par la clé kid
+func (i *testIssuer) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	i.mutex.Lock()
+	key := i.keys[kid]
+	i.mutex.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+// claims retourne des claims valides pour l'émetteur de test
+func (i *testIssuer) claims(overrides jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":  i.server.URL,
+		"sub":  "user-1",
+		"aud":  "sqlitrest-api",
+		"azp":  "web-app",
+		"role": "authenticated",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func newOIDCManager(t *testing.T, config JWTConfig) *JWTManager {
+	t.Helper()
+	config.Enabled = true
+	config.Algorithm = "RS256"
+	manager, err := NewJWTManager(config)
+	if err != nil {
+		t.Fatalf("NewJWTManager: %v", err)
+	}
+	t.Cleanup(manager.Close)
+	return manager
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key-1")
+
+	manager := newOIDCManager(t, JWTConfig{IssuerURL: issuer.server.URL + "/"})
+
+	claims, err := manager.ValidateToken(issuer.sign(t, "key-1", issuer.claims(nil)))
+	if err != nil {
+		t.Fatalf("token from discovered issuer rejected: %v", err)
+	}
+	if claims.UserID != "user-1" || claims.Role != "authenticated" {
+		t.Errorf("unexpected claims: user %q, role %q", claims.UserID, claims.Role)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key-1")
+	issuer.issuer = "https://attacker.example.com"
+
+	manager := newOIDCManager(t, JWTConfig{IssuerURL: issuer.server.URL})
+
+	if _, err := manager.ValidateToken(issuer.sign(t, "key-1", issuer.claims(nil))); err == nil {
+		t.Fatal("token accepted although the discovery document announces another issuer")
+	}
+	if issuer.fetches() != 0 {
+		t.Errorf("JWKS fetched %d times after an issuer mismatch", issuer.fetches())
+	}
+}
+
+func TestOIDCJWKSCaching(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key-1")
+	issuer.cacheControl = "public, max-age=3600"
+
+	manager := newOIDCManager(t, JWTConfig{IssuerURL: issuer.server.URL})
+
+	for n := 0; n < 3; n++ {
+		if _, err := manager.ValidateToken(issuer.sign(t, "key-1", issuer.claims(nil))); err != nil {
+			t.Fatalf("validation %d: %v", n, err)
+		}
+	}
+	if fetches := issuer.fetches(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1 while cached", fetches)
+	}
+
+	// Rotation : un kid inconnu force un rafraîchissement, mais pas avant le délai minimal
+	issuer.addKey(t, "key-2")
+	rotated := issuer.sign(t, "key-2", issuer.claims(nil))
+	if _, err := manager.ValidateToken(rotated); err == nil {
+		t.Fatal("unknown kid accepted before the forced refresh delay")
+	}
+	if fetches := issuer.fetches(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1 within the forced refresh delay", fetches)
+	}
+
+	manager.remoteKeys.mutex.Lock()
+	manager.remoteKeys.lastFetch = time.Now().Add(-minJWKSForcedRefresh)
+	manager.remoteKeys.mutex.Unlock()
+
+	if _, err := manager.ValidateToken(rotated); err != nil {
+		t.Fatalf("rotated key rejected after refresh: %v", err)
+	}
+	if fetches := issuer.fetches(); fetches != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2 after an unknown kid", fetches)
+	}
+}
+
+func TestOIDCClaimValidation(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key-1")
+
+	tests := []struct {
+		name      string
+		config    JWTConfig
+		overrides jwt.MapClaims
+		valid     bool
+	}{
+		{"valid", JWTConfig{Audience: []string{"sqlitrest-api"}}, nil, true},
+		{"wrong issuer", JWTConfig{}, jwt.MapClaims{"iss": "https://other.example.com"}, false},
+		{"missing issuer", JWTConfig{}, jwt.MapClaims{"iss": nil}, false},
+		{"audience among several", JWTConfig{Audience: []string{"sqlitrest-api"}}, jwt.MapClaims{"aud": []string{"account", "sqlitrest-api"}}, true},
+		{"wrong audience", JWTConfig{Audience: []string{"sqlitrest-api"}}, jwt.MapClaims{"aud": "account"}, false},
+		{"missing audience", JWTConfig{Audience: []string{"sqlitrest-api"}}, jwt.MapClaims{"aud": nil}, false},
+		{"azp is a client id, not an audience", JWTConfig{Audience: []string{"sqlitrest-api"}}, jwt.MapClaims{"azp": "spa-client"}, true},
+		{"authorized party", JWTConfig{AuthorizedParties: []string{"web-app"}}, nil, true},
+		{"unauthorized party", JWTConfig{AuthorizedParties: []string{"mobile-app"}}, nil, false},
+		{"missing authorized party", JWTConfig{AuthorizedParties: []string{"web-app"}}, jwt.MapClaims{"azp": nil}, false},
+		{"expired", JWTConfig{}, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := test.config
+			config.IssuerURL = issuer.server.URL
+			manager := newOIDCManager(t, config)
+
+			_, err := manager.ValidateToken(issuer.sign(t, "key-1", issuer.claims(test.overrides)))
+			if test.valid && err != nil {
+				t.Errorf("token rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestOIDCClaimMapping(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key-1")
+
+	tests := []struct {
+		name        string
+		config      JWTConfig
+		overrides   jwt.MapClaims
+		role        string
+		tenant      string
+		permissions []string
+	}{
+		{"tenant from a nested claim", JWTConfig{TenantClaimKey: ".org.id"}, jwt.MapClaims{"tenant_id": 42, "org": map[string]interface{}{"id": "acme"}}, "authenticated", "acme", nil},
+		{"numeric tenant", JWTConfig{}, jwt.MapClaims{"tenant_id": 42}, "authenticated", "42", nil},
+		{"permissions as a string", JWTConfig{}, jwt.MapClaims{"permissions": "read:all"}, "authenticated", "", []string{"read:all"}},
+		{"role of another shape", JWTConfig{}, jwt.MapClaims{"role": []string{"x"}}, "", "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := test.config
+			config.IssuerURL = issuer.server.URL
+			manager := newOIDCManager(t, config)
+
+			claims, err := manager.ValidateToken(issuer.sign(t, "key-1", issuer.claims(test.overrides)))
+			if err != nil {
+				t.Fatalf("token rejected: %v", err)
+			}
+			if claims.Role != test.role || claims.TenantID != test.tenant {
+				t.Errorf("role %q, tenant %q; want %q, %q", claims.Role, claims.TenantID, test.role, test.tenant)
+			}
+			if strings.Join(claims.Permissions, ",") != strings.Join(test.permissions, ",") {
+				t.Errorf("permissions %v, want %v", claims.Permissions, test.permissions)
+			}
+		})
+	}
+}
+
+func TestOIDCRejectsForeignKey(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.addKey(t, "key-1")
+	other := newTestIssuer(t)
+	other.addKey(t, "key-1")
+
+	manager := newOIDCManager(t, JWTConfig{IssuerURL: issuer.server.URL})
+
+	// Même kid, même iss, mais signé par une clé absente du JWKS de l'émetteur
+	forged := other.sign(t, "key-1", issuer.claims(nil))
+	if _, err := manager.ValidateToken(forged); err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Fatalf("token signed by a foreign key accepted: %v", err)
+	}
+}
+
+func TestCacheDuration(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{}, defaultJWKSCacheDuration},
+		{http.Header{"Cache-Control": {"public, max-age=600"}}, 10 * time.Minute},
+		{http.Header{"Cache-Control": {"max-age=1"}}, minJWKSCacheDuration},
+		{http.Header{"Cache-Control": {"max-age=31536000"}}, maxJWKSCacheDuration},
+		{http.Header{"Cache-Control": {"no-store"}}, minJWKSCacheDuration},
+	}
+
+	for _, test := range tests {
+		if got := cacheDuration(test.header); got != test.want {
+			t.Errorf("cacheDuration(%v) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
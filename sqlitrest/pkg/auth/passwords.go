@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Paramètres argon2id des nouveaux hachages (recommandations OWASP)
+const (
+	argon2Memory  = 64 * 1024
+	argon2Time    = 3
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+var (
+	dummyPasswordHash string
+	dummyPasswordOnce sync.Once
+)
+
+// verifyDummyPassword consomme le même temps qu'une vérification réelle,
+// pour ne pas révéler l'existence d'un utilisateur
+func verifyDummyPassword(password string) {
+	dummyPasswordOnce.Do(func() {
+		dummyPasswordHash, _ = HashPassword("sqlitrest-dummy-password")
+	})
+	VerifyPassword(password, dummyPasswordHash)
+}
+
+// HashPassword hache un mot de passe en argon2id au format PHC
+// ($argon2id$v=19$m=...,t=...,p=...$sel$hash)
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// VerifyPassword vérifie un mot de passe contre un hachage bcrypt ($2a$, $2b$, $2y$)
+// ou argon2id/argon2i au format PHC
+func VerifyPassword(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+
+	case strings.HasPrefix(encoded, "$argon2id$"), strings.HasPrefix(encoded, "$argon2i$"):
+		return verifyArgon2(password, encoded)
+	}
+
+	return false, fmt.Errorf("unsupported password hash format")
+}
+
+// verifyArgon2 vérifie un hachage argon2 au format PHC
+func verifyArgon2(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, fmt.Errorf("invalid argon2 hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2 hash: %w", err)
+	}
+
+	var actual []byte
+	if parts[1] == "argon2id" {
+		actual = argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(expected)))
+	} else {
+		actual = argon2.Key([]byte(password), salt, time, memory, threads, uint32(len(expected)))
+	}
+
+	return subtle.ConstantTimeCompare(actual, expected) == 1, nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Relecture périodique des tables (révocations faites par un autre processus)
+	revocationReloadInterval = 30 * time.Second
+	// Conservation d'une révocation dont l'expiration du token est inconnue
+	defaultRevocationRetention = 7 * 24 * time.Hour
+)
+
+// RevocationStore conserve les tokens révoqués (liste de jti) et les dates de
+// coupure par utilisateur. Les deux tables sont gardées en mémoire : la liste
+// ne contient que les tokens non expirés et reste donc petite.
+type RevocationStore struct {
+	db       *sql.DB
+	revoked  map[string]time.Time // jti -> expiration du token
+	cutoffs  map[string]time.Time // user_id -> tokens émis avant cette date refusés
+	mutex    sync.RWMutex
+	stopChan chan struct{}
+}
+
+// NewRevocationStore crée le magasin de révocations et ses tables si besoin
+func NewRevocationStore(db *sql.DB) (*RevocationStore, error) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS _revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		user_id TEXT,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		reason TEXT
+	);
+	CREATE TABLE IF NOT EXISTS _token_cutoffs (
+		user_id TEXT PRIMARY KEY,
+		not_before DATETIME NOT NULL,
+		reason TEXT
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create revocation tables: %w", err)
+	}
+
+	s := &RevocationStore{
+		db:      db,
+		revoked: make(map[string]time.Time),
+		cutoffs: make(map[string]time.Time),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// reload recharge le cache depuis la base et purge les révocations expirées
+func (s *RevocationStore) reload() error {
+	now := time.Now().UTC()
+	if _, err := s.db.Exec("DELETE FROM _revoked_tokens WHERE expires_at < ?", now); err != nil {
+		return fmt.Errorf("failed to purge revoked tokens: %w", err)
+	}
+
+	revoked := make(map[string]time.Time)
+	rows, err := s.db.Query("SELECT jti, expires_at FROM _revoked_tokens")
+	if err != nil {
+		return fmt.Errorf("failed to load revoked tokens: %w", err)
+	}
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan revoked token: %w", err)
+		}
+		revoked[jti] = expiresAt
+	}
+	rows.Close()
+
+	cutoffs := make(map[string]time.Time)
+	rows, err = s.db.Query("SELECT user_id, not_before FROM _token_cutoffs")
+	if err != nil {
+		return fmt.Errorf("failed to load token cutoffs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var notBefore time.Time
+		if err := rows.Scan(&userID, &notBefore); err != nil {
+			return fmt.Errorf("failed to scan token cutoff: %w", err)
+		}
+		cutoffs[userID] = notBefore
+	}
+
+	s.mutex.Lock()
+	s.revoked = revoked
+	s.cutoffs = cutoffs
+	s.mutex.Unlock()
+	return rows.Err()
+}
+
+// StartReloading relit périodiquement les tables pour suivre les révocations
+// faites hors du serveur (CLI, autre instance)
+func (s *RevocationStore) StartReloading() {
+	s.stopChan = make(chan struct{})
+	go func(stop <-chan struct{}) {
+		ticker := time.NewTicker(revocationReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.reload(); err != nil {
+					log.Printf("Failed to reload token revocations: %v", err)
+				}
+			}
+		}
+	}(s.stopChan)
+}
+
+// Close arrête la relecture périodique
+func (s *RevocationStore) Close() {
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
+
+// RevokeToken révoque un token par son jti jusqu'à son expiration
+// (expiresAt nul : conservation par défaut de 7 jours)
+func (s *RevocationStore) RevokeToken(jti, userID string, expiresAt time.Time, reason string) error {
+	if jti == "" {
+		return fmt.Errorf("jti is required")
+	}
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(defaultRevocationRetention)
+	}
+	expiresAt = expiresAt.UTC()
+
+	_, err := s.db.Exec(`INSERT INTO _revoked_tokens (jti, user_id, expires_at, revoked_at, reason) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(jti) DO UPDATE SET expires_at = excluded.expires_at, reason = excluded.reason`,
+		jti, nullString(userID), expiresAt, time.Now().UTC(), nullString(reason))
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.revoked[jti] = expiresAt
+	s.mutex.Unlock()
+	return nil
+}
+
+// RevokeUser refuse tous les tokens d'un utilisateur émis jusqu'à maintenant
+func (s *RevocationStore) RevokeUser(userID, reason string) (time.Time, error) {
+	if userID == "" {
+		return time.Time{}, fmt.Errorf("user_id is required")
+	}
+
+	// iat est exprimé en secondes : la coupure l'est aussi
+	notBefore := time.Now().UTC().Truncate(time.Second)
+	_, err := s.db.Exec(`INSERT INTO _token_cutoffs (user_id, not_before, reason) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET not_before = excluded.not_before, reason = excluded.reason`,
+		userID, notBefore, nullString(reason))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.cutoffs[userID] = notBefore
+	s.mutex.Unlock()
+	return notBefore, nil
+}
+
+// IsRevoked indique si des claims validés correspondent à un token révoqué
+func (s *RevocationStore) IsRevoked(claims *Claims) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if claims.ID != "" {
+		if _, revoked := s.revoked[claims.ID]; revoked {
+			return true
+		}
+	}
+
+	if cutoff, exists := s.cutoffs[claims.UserID]; exists {
+		// Sans iat, la date d'émission est inconnue : le token est refusé
+		if claims.IssuedAt == nil || !claims.IssuedAt.Time.After(cutoff) {
+			return true
+		}
+	}
+
+	return false
+}
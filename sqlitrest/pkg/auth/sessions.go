@@ -0,0 +1,373 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// ErrInvalidCredentials est retournée pour un identifiant ou un mot de passe invalide
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrInvalidRefreshToken est retournée pour un refresh token inconnu, expiré ou révoqué
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// LoginConfig configure les endpoints /auth/login, /auth/refresh et /auth/logout
+type LoginConfig struct {
+	Enabled         bool   `toml:"enabled"`
+	Database        string `toml:"database"`          // défaut: main
+	Table           string `toml:"table"`             // défaut: users
+	IdentityColumn  string `toml:"identity_column"`   // défaut: email
+	PasswordColumn  string `toml:"password_column"`   // défaut: password_hash (bcrypt ou argon2)
+	UserIDColumn    string `toml:"user_id_column"`    // défaut: id
+	RoleColumn      string `toml:"role_column"`       // optionnel, ex: role
+	TenantColumn    string `toml:"tenant_column"`     // optionnel
+	DefaultRole     string `toml:"default_role"`      // rôle si la colonne est vide (défaut: user)
+	AccessTokenTTL  string `toml:"access_token_ttl"`  // défaut: 15m
+	RefreshTokenTTL string `toml:"refresh_token_ttl"` // défaut: 720h
+}
+
+// TokenPair est la réponse des endpoints de login et de refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// loginUser est un utilisateur lu depuis la table configurée
+type loginUser struct {
+	id       string
+	role     string
+	tenantID string
+}
+
+// SessionManager authentifie les utilisateurs par mot de passe et gère
+// les refresh tokens (table _refresh_tokens, rotation à chaque usage)
+type SessionManager struct {
+	db              *sql.DB
+	jwtManager      *JWTManager
+	config          LoginConfig
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+// sessionStore exécute les requêtes de session, sur la base ou dans une transaction
+type sessionStore interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewSessionManager crée un gestionnaire de sessions et vérifie la table des
+// utilisateurs ainsi que la possibilité de signer les access tokens
+func NewSessionManager(db *sql.DB, jwtManager *JWTManager, config LoginConfig) (*SessionManager, error) {
+	if err := jwtManager.CanSign(); err != nil {
+		return nil, fmt.Errorf("login requires token signing: %w", err)
+	}
+
+	if config.Table == "" {
+		config.Table = "users"
+	}
+	if config.IdentityColumn == "" {
+		config.IdentityColumn = "email"
+	}
+	if config.PasswordColumn == "" {
+		config.PasswordColumn = "password_hash"
+	}
+	if config.UserIDColumn == "" {
+		config.UserIDColumn = "id"
+	}
+	if config.DefaultRole == "" {
+		config.DefaultRole = "user"
+	}
+
+	s := &SessionManager{
+		db:              db,
+		jwtManager:      jwtManager,
+		config:          config,
+		accessTokenTTL:  defaultAccessTokenTTL,
+		refreshTokenTTL: defaultRefreshTokenTTL,
+	}
+
+	var err error
+	if config.AccessTokenTTL != "" {
+		if s.accessTokenTTL, err = time.ParseDuration(config.AccessTokenTTL); err != nil {
+			return nil, fmt.Errorf("invalid access_token_ttl: %w", err)
+		}
+	}
+	if config.RefreshTokenTTL != "" {
+		if s.refreshTokenTTL, err = time.ParseDuration(config.RefreshTokenTTL); err != nil {
+			return nil, fmt.Errorf("invalid refresh_token_ttl: %w", err)
+		}
+	}
+
+	if err := s.checkUsersTable(); err != nil {
+		return nil, err
+	}
+
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS _refresh_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash TEXT NOT NULL UNIQUE,
+		family_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		revoked_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON _refresh_tokens(family_id);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create refresh tokens table: %w", err)
+	}
+
+	return s, nil
+}
+
+// checkUsersTable vérifie que la table des utilisateurs contient les colonnes configurées
+func (s *SessionManager) checkUsersTable() error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(s.config.Table)))
+	if err != nil {
+		return fmt.Errorf("failed to inspect login table %s: %w", s.config.Table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to inspect login table %s: %w", s.config.Table, err)
+		}
+		columns[name] = true
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("login table %s not found", s.config.Table)
+	}
+
+	for _, column := range []string{s.config.IdentityColumn, s.config.PasswordColumn, s.config.UserIDColumn, s.config.RoleColumn, s.config.TenantColumn} {
+		if column != "" && !columns[column] {
+			return fmt.Errorf("login table %s has no column %s", s.config.Table, column)
+		}
+	}
+
+	return rows.Err()
+}
+
+// IdentityField retourne le champ attendu dans le corps de /auth/login
+func (s *SessionManager) IdentityField() string {
+	return s.config.IdentityColumn
+}
+
+// Login vérifie les identifiants et émet un access token et un refresh token
+func (s *SessionManager) Login(identity, password string) (*TokenPair, error) {
+	if identity == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s = ?",
+		s.userColumns(), quoteIdent(s.config.PasswordColumn), quoteIdent(s.config.Table), quoteIdent(s.config.IdentityColumn))
+
+	var user loginUser
+	var role, tenantID, passwordHash sql.NullString
+	err := s.db.QueryRow(query, identity).Scan(&user.id, &role, &tenantID, &passwordHash)
+	if err == sql.ErrNoRows || (err == nil && !passwordHash.Valid) {
+		verifyDummyPassword(password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	ok, err := VerifyPassword(password, passwordHash.String)
+	if err != nil {
+		log.Printf("Cannot verify password of user %s: %v", user.id, err)
+		return nil, ErrInvalidCredentials
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	user.role, user.tenantID = s.roleOf(role), tenantID.String
+	return s.issue(s.db, user, newFamilyID())
+}
+
+// Refresh consomme un refresh token et en émet un nouveau dans la même famille.
+// La réutilisation d'un token déjà consommé révoque toute la famille (vol probable).
+// La rotation n'est validée qu'une fois les nouveaux tokens émis : en cas d'échec,
+// l'ancien refresh token reste utilisable.
+func (s *SessionManager) Refresh(refreshToken string) (*TokenPair, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var familyID, userID string
+	var expiresAt time.Time
+	var revokedAt sql.NullTime
+	err = tx.QueryRow("SELECT id, family_id, user_id, expires_at, revoked_at FROM _refresh_tokens WHERE token_hash = ?",
+		hashToken(refreshToken)).Scan(&id, &familyID, &userID, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if revokedAt.Valid {
+		log.Printf("Refresh token reuse detected for user %s, revoking session %s", userID, familyID)
+		tx.Exec("UPDATE _refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now, familyID)
+		tx.Commit()
+		return nil, ErrInvalidRefreshToken
+	}
+	if now.After(expiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	result, err := tx.Exec("UPDATE _refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", now, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrInvalidRefreshToken // consommé par une requête concurrente
+	}
+
+	// Relire l'utilisateur : un changement de rôle ou une suppression s'applique au refresh
+	user, err := s.loadUser(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issue(tx, *user, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	return pair, nil
+}
+
+// Logout révoque la session (famille) du refresh token
+func (s *SessionManager) Logout(refreshToken string) error {
+	var familyID string
+	err := s.db.QueryRow("SELECT family_id FROM _refresh_tokens WHERE token_hash = ?", hashToken(refreshToken)).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load refresh token: %w", err)
+	}
+
+	_, err = s.db.Exec("UPDATE _refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", time.Now().UTC(), familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUser révoque toutes les sessions d'un utilisateur
+func (s *SessionManager) RevokeUser(userID string) error {
+	_, err := s.db.Exec("UPDATE _refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// issue émet un access token et enregistre un nouveau refresh token
+func (s *SessionManager) issue(store sessionStore, user loginUser, familyID string) (*TokenPair, error) {
+	accessToken, err := s.jwtManager.GenerateTokenWithTTL(user.id, user.role, user.tenantID, nil, s.accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = store.Exec("INSERT INTO _refresh_tokens (token_hash, family_id, user_id, expires_at, created_at) VALUES (?, ?, ?, ?, ?)",
+		hashToken(refreshToken), familyID, user.id, time.Now().UTC().Add(s.refreshTokenTTL), time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// loadUser relit un utilisateur par son identifiant
+func (s *SessionManager) loadUser(store sessionStore, userID string) (*loginUser, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?",
+		s.userColumns(), quoteIdent(s.config.Table), quoteIdent(s.config.UserIDColumn))
+
+	var user loginUser
+	var role, tenantID sql.NullString
+	err := store.QueryRow(query, userID).Scan(&user.id, &role, &tenantID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	user.role, user.tenantID = s.roleOf(role), tenantID.String
+	return &user, nil
+}
+
+// userColumns retourne la liste "id, role, tenant" à sélectionner (NULL si non configurée)
+func (s *SessionManager) userColumns() string {
+	columns := []string{"CAST(" + quoteIdent(s.config.UserIDColumn) + " AS TEXT)", "NULL", "NULL"}
+	if s.config.RoleColumn != "" {
+		columns[1] = quoteIdent(s.config.RoleColumn)
+	}
+	if s.config.TenantColumn != "" {
+		columns[2] = "CAST(" + quoteIdent(s.config.TenantColumn) + " AS TEXT)"
+	}
+	return strings.Join(columns, ", ")
+}
+
+func (s *SessionManager) roleOf(role sql.NullString) string {
+	if role.Valid && role.String != "" {
+		return role.String
+	}
+	return s.config.DefaultRole
+}
+
+// randomToken génère un jeton opaque de 256 bits
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newFamilyID() string {
+	token, _ := randomToken()
+	return token[:16]
+}
+
+// quoteIdent échappe un identifiant SQL
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,296 @@
+package codegen
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/cl-ment/sqlitrest/pkg/openapi"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Options paramètre la génération d'un client
+type Options struct {
+	Database string // base décrite par le document
+	Package  string // nom du package Go (défaut: client)
+}
+
+// table décrit une table pour les templates
+type table struct {
+	Name        string
+	TypeName    string // nom de type exporté (users -> Users)
+	Description string
+	Columns     []column
+	Insert      bool
+	Update      bool
+	Delete      bool
+}
+
+// column décrit une colonne pour les templates
+type column struct {
+	Name        string
+	FieldName   string // nom de champ Go exporté (author_id -> AuthorID)
+	Type        string // type OpenAPI : integer, number, boolean, string
+	Nullable    bool
+	Required    bool // requis à l'insertion
+	ReadOnly    bool // colonne générée, absente des insertions et modifications
+	Description string
+	Enum        []interface{} // valeurs permises (CHECK col IN (...))
+}
+
+// Writable retourne les colonnes acceptées par les insertions et modifications
+func (t table) Writable() []column {
+	var columns []column
+	for _, c := range t.Columns {
+		if !c.ReadOnly {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// templateData est passé aux templates de client
+type templateData struct {
+	Database string
+	Package  string
+	Tables   []table
+}
+
+// Generate génère un client typé ("ts" ou "go") depuis un document OpenAPI
+func Generate(lang string, doc *openapi.OpenAPIDoc, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "client"
+	}
+
+	tables, err := collectTables(doc, opts.Database)
+	if err != nil {
+		return nil, err
+	}
+	data := templateData{Database: opts.Database, Package: opts.Package, Tables: tables}
+
+	switch lang {
+	case "ts", "typescript":
+		return render("client.ts.tmpl", data)
+	case "go":
+		source, err := render("client.go.tmpl", data)
+		if err != nil {
+			return nil, err
+		}
+		formatted, err := format.Source(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format generated Go code: %w", err)
+		}
+		return formatted, nil
+	}
+
+	return nil, fmt.Errorf("unsupported language %q (expected ts or go)", lang)
+}
+
+// render exécute un template de client
+func render(name string, data templateData) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"tsType":   tsType,
+		"goType":   goType,
+		"tsString": tsString,
+		"tsKey":    tsKey,
+		"comment":  comment,
+	}).ParseFS(templates, "templates/"+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// collectTables extrait les tables, leurs colonnes et opérations du document
+func collectTables(doc *openapi.OpenAPIDoc, database string) ([]table, error) {
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tables []table
+	usedTypes := make(map[string]string)
+	for _, name := range names {
+		item, ok := doc.Paths[fmt.Sprintf("/%s/%s", database, name)].(map[string]interface{})
+		if !ok {
+			continue // schéma sans path (table non exposée)
+		}
+
+		schema := doc.Components.Schemas[name]
+		t := table{
+			Name:        name,
+			TypeName:    exportedName(name),
+			Description: schema.Description,
+		}
+		if reservedNames[t.TypeName] {
+			t.TypeName += "Row"
+		}
+		_, t.Insert = item["post"]
+		_, t.Update = item["patch"]
+		_, t.Delete = item["delete"]
+
+		if previous, exists := usedTypes[t.TypeName]; exists {
+			return nil, fmt.Errorf("tables %s and %s both map to type %s", previous, name, t.TypeName)
+		}
+		usedTypes[t.TypeName] = name
+
+		required := make(map[string]bool, len(schema.Required))
+		for _, column := range schema.Required {
+			required[column] = true
+		}
+
+		columns := make([]string, 0, len(schema.Properties))
+		for column := range schema.Properties {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		for _, name := range columns {
+			property := schema.Properties[name]
+			c := column{
+				Name:        name,
+				FieldName:   exportedName(name),
+				Required:    required[name],
+				Description: property.Description,
+				ReadOnly:    property.ReadOnly,
+				Enum:        property.Enum,
+			}
+			switch typ := property.Type.(type) {
+			case string:
+				c.Type = typ
+			case []string:
+				// Colonne nullable : [type, "null"]
+				c.Type = typ[0]
+				c.Nullable = true
+			}
+			t.Columns = append(t.Columns, c)
+		}
+
+		tables = append(tables, t)
+	}
+
+	return tables, nil
+}
+
+// tsType retourne le type TypeScript d'une colonne ; une énumération devient
+// une union de littéraux
+func tsType(c column) string {
+	typ := "string"
+	switch c.Type {
+	case "integer", "number":
+		typ = "number"
+	case "boolean":
+		typ = "boolean"
+	}
+	if literals := tsLiterals(c.Enum); literals != "" {
+		typ = literals
+	}
+	if c.Nullable {
+		typ += " | null"
+	}
+	return typ
+}
+
+// tsLiterals retourne l'union TypeScript des valeurs d'une énumération
+func tsLiterals(enum []interface{}) string {
+	var literals []string
+	for _, value := range enum {
+		switch v := value.(type) {
+		case nil:
+			continue // porté par "| null"
+		case string:
+			literals = append(literals, tsString(v))
+		case int64, float64:
+			literals = append(literals, fmt.Sprint(v))
+		default:
+			return ""
+		}
+	}
+	return strings.Join(literals, " | ")
+}
+
+// goType retourne le type Go d'une colonne ; les colonnes nullables sont des pointeurs
+func goType(c column) string {
+	typ := "string"
+	switch c.Type {
+	case "integer":
+		typ = "int64"
+	case "number":
+		typ = "float64"
+	case "boolean":
+		typ = "bool"
+	}
+	if c.Nullable {
+		typ = "*" + typ
+	}
+	return typ
+}
+
+// tsString échappe une chaîne pour un littéral TypeScript
+func tsString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// comment ramène un texte libre (description, CHECK sur plusieurs lignes) à une
+// seule ligne de commentaire, valable en Go comme dans un bloc /** */
+func comment(text string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(text), " "), "*/", "* /")
+}
+
+// tsKey retourne une clé de propriété TypeScript, entre guillemets si nécessaire
+func tsKey(name string) string {
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || r == '$' || (i > 0 && unicode.IsDigit(r))) {
+			return tsString(name)
+		}
+	}
+	return name
+}
+
+// reservedNames sont les identifiants des clients générés qu'une table ne peut pas prendre
+var reservedNames = map[string]bool{
+	"Client": true, "ClientOptions": true, "Cond": true, "Database": true, "Error": true,
+	"From": true, "List": true, "New": true, "Operator": true, "Query": true,
+	"ErrorBody": true, "SQLitRESTError": true, "Tables": true, "Violation": true, "WriteResult": true,
+}
+
+// commonInitialisms sont écrits en majuscules dans les noms Go (user_id -> UserID)
+var commonInitialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "URI": true, "URL": true, "UUID": true,
+}
+
+// exportedName convertit un identifiant SQL en nom exporté (author_id -> AuthorID)
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if upper := strings.ToUpper(word); commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+
+	result := b.String()
+	if result == "" || unicode.IsDigit([]rune(result)[0]) {
+		result = "T" + result
+	}
+	return result
+}
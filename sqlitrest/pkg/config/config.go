@@ -5,18 +5,35 @@ import (
 	"os"
 
 	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/cl-ment/sqlitrest/pkg/engine"
+	"github.com/cl-ment/sqlitrest/pkg/policies"
+	"github.com/cl-ment/sqlitrest/pkg/ratelimit"
+	"github.com/cl-ment/sqlitrest/pkg/rpc"
 	"github.com/pelletier/go-toml/v2"
 )
 
 type Config struct {
-	Server    ServerConfig     `toml:"server"`
-	Databases []DatabaseConfig `toml:"databases"`
-	Auth      AuthConfig       `toml:"auth"`
+	Server     ServerConfig            `toml:"server"`
+	Databases  []DatabaseConfig        `toml:"databases"`
+	Auth       AuthConfig              `toml:"auth"`
+	Policies   policies.SourceConfig   `toml:"policies"`
+	RateLimit  ratelimit.Config        `toml:"rate_limit"`
+	RPC        rpc.Config              `toml:"rpc"`
+	Pagination engine.PaginationConfig `toml:"pagination"`
 }
 
 type ServerConfig struct {
-	Host string `toml:"host"`
-	Port int    `toml:"port"`
+	Host      string `toml:"host"`
+	Port      int    `toml:"port"`
+	PublicURL string `toml:"public_url"` // URL publique annoncée (proxy), défaut: http://host:port
+}
+
+// URL retourne l'URL publique du serveur
+func (s ServerConfig) URL() string {
+	if s.PublicURL != "" {
+		return s.PublicURL
+	}
+	return fmt.Sprintf("http://%s:%d", s.Host, s.Port)
 }
 
 type DatabaseConfig struct {
@@ -26,7 +43,9 @@ type DatabaseConfig struct {
 }
 
 type AuthConfig struct {
-	JWT auth.JWTConfig `toml:"jwt"`
+	JWT    auth.JWTConfig   `toml:"jwt"`
+	Grants []auth.GrantRule `toml:"grants"`
+	Login  auth.LoginConfig `toml:"login"`
 }
 
 type JWTConfig struct {
@@ -54,6 +73,15 @@ func Load() (*Config, error) {
 				Algorithm: "HS256",
 				Secret:    "change-me",
 			},
+			// Seul le rôle admin a des droits par défaut ; les autres rôles, anonyme
+			// compris, n'ont accès à rien tant qu'une section [[auth.grants]] ne
+			// le déclare pas (elle remplace alors ces défauts)
+			Grants: []auth.GrantRule{
+				{Role: "admin", Privileges: []string{"ALL"}, Objects: []string{"*", "_*"}},
+			},
+		},
+		Policies: policies.SourceConfig{
+			Mode: policies.ModeMerge,
 		},
 	}
 
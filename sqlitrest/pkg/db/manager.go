@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/cl-ment/sqlitrest/pkg/config"
 	_ "modernc.org/sqlite" // zombiezen utilise modernc en interne
@@ -20,6 +21,15 @@ type Database struct {
 	Readers []*sql.DB
 	path    string
 	mode    string
+	next    atomic.Uint32
+}
+
+// Reader retourne une connexion de lecture à tour de rôle, le writer s'il n'y en a pas
+func (d *Database) Reader() *sql.DB {
+	if len(d.Readers) == 0 {
+		return d.Writer
+	}
+	return d.Readers[int(d.next.Add(1)-1)%len(d.Readers)]
 }
 
 func NewManager(cfg *config.Config) (*Manager, error) {
@@ -138,7 +148,8 @@ func (m *Manager) createWriter(path string) (*sql.DB, error) {
 func (m *Manager) createReaders(path string, count int) []*sql.DB {
 	readers := make([]*sql.DB, count)
 	for i := 0; i < count; i++ {
-		db, _ := sql.Open("sqlite", path+"?mode=ro")
+		// query_only refuse toute écriture sur ces connexions
+		db, _ := sql.Open("sqlite", path+"?_pragma=query_only(1)")
 		db.Exec("PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL; PRAGMA foreign_keys=ON;")
 		readers[i] = db
 	}
@@ -23,13 +23,27 @@ func NewSQLBuilder() *SQLBuilder {
 
 // BuildSelect construit une requête SELECT complète
 func (b *SQLBuilder) BuildSelect(params *QueryParameters) (string, []interface{}, error) {
+	return b.buildSelect(fmt.Sprintf("FROM %s", b.quoteIdentifier(params.Table)), params, nil)
+}
+
+// BuildSelectFrom applique select, filtres, tri et pagination au résultat d'une
+// sous-requête (fonction RPC), nommé d'après params.Table. Les arguments retournés
+// correspondent aux paramètres anonymes placés après la sous-requête.
+func (b *SQLBuilder) BuildSelectFrom(subquery string, params *QueryParameters) (string, []interface{}, error) {
+	subquery = strings.TrimRight(strings.TrimSpace(subquery), ";")
+	return b.buildSelect(fmt.Sprintf("FROM (%s) AS %s", subquery, b.quoteIdentifier(params.Table)), params, nil)
+}
+
+// buildSelect assemble une requête SELECT autour d'une clause FROM ; relations
+// contient les relations de select résolues (voir ResourceEmbedding.ResolveRelations)
+func (b *SQLBuilder) buildSelect(fromClause string, params *QueryParameters, relations []EmbeddedRelation) (string, []interface{}, error) {
 	var args []interface{}
 
 	// Construction de la clause SELECT
-	selectClause := b.buildSelectClause(params.Select)
-
-	// Construction de la clause FROM
-	fromClause := fmt.Sprintf("FROM %s", b.quoteIdentifier(params.Table))
+	selectClause, err := b.buildSelectClause(params.Select, params.Table, relations)
+	if err != nil {
+		return "", nil, err
+	}
 
 	// Construction de la clause WHERE
 	whereClause, whereArgs := b.buildWhereClause(params)
@@ -42,46 +56,45 @@ func (b *SQLBuilder) BuildSelect(params *QueryParameters) (string, []interface{}
 	limitClause, limitArgs := b.buildLimitClause(params.Limit, params.Offset)
 	args = append(args, limitArgs...)
 
-	// Assemblage final
-	query := fmt.Sprintf("SELECT %s %s %s %s %s",
-		selectClause, fromClause, whereClause, orderClause, limitClause)
-
-	// Nettoyage des espaces multiples
-	query = strings.TrimSpace(query)
-	query = strings.ReplaceAll(query, "  ", " ")
+	// Assemblage final, sans les clauses vides (la sous-requête n'est pas retouchée)
+	parts := []string{"SELECT " + selectClause, fromClause}
+	for _, clause := range []string{whereClause, orderClause, limitClause} {
+		if clause != "" {
+			parts = append(parts, clause)
+		}
+	}
+	query := strings.Join(parts, " ")
 
 	return query, args, nil
 }
 
-// BuildSelectWithEmbedding construit une requête SELECT avec resource embedding
-func (b *SQLBuilder) BuildSelectWithEmbedding(params *QueryParameters, embedding *ResourceEmbedding) (string, []interface{}, error) {
-	// Construire la requête de base
-	query, args, err := b.BuildSelect(params)
-	if err != nil {
-		return "", nil, err
-	}
+// BuildSelectWithEmbedding construit une requête SELECT avec resource embedding.
+// Chaque relation est lue par une sous-requête corrélée qui retourne un objet JSON
+// (relation directe) ou un tableau JSON (relation inverse) ; les politiques de la
+// table incluse s'y appliquent comme à toute table lue.
+func (b *SQLBuilder) BuildSelectWithEmbedding(params *QueryParameters, relations []EmbeddedRelation) (string, []interface{}, error) {
+	return b.buildSelect(fmt.Sprintf("FROM %s", b.quoteIdentifier(params.Table)), params, relations)
+}
 
-	// Parser les relations depuis select - convertir []string en string
-	selectStr := "*"
-	if len(params.Select) > 0 {
-		selectStr = strings.Join(params.Select, ",")
-	}
+// buildEmbeddedColumn construit la sous-requête d'une relation incluse
+func (b *SQLBuilder) buildEmbeddedColumn(baseTable string, relation EmbeddedRelation, index int) string {
+	alias := b.quoteIdentifier(fmt.Sprintf("embedded_%d", index))
 
-	relations, err := ParseEmbeddedRelations(selectStr)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to parse embedded relations: %w", err)
+	pairs := make([]string, 0, len(relation.Columns))
+	for _, column := range relation.Columns {
+		pairs = append(pairs, fmt.Sprintf("'%s', %s.%s",
+			strings.ReplaceAll(column, "'", "''"), alias, b.quoteIdentifier(column)))
 	}
-
-	// Appliquer l'embedding si nécessaire
-	if len(relations) > 0 && embedding != nil {
-		embeddedQuery, err := embedding.BuildEmbeddedQuery(query, relations, params.Table)
-		if err != nil {
-			return "", nil, fmt.Errorf("failed to build embedded query: %w", err)
-		}
-		return embeddedQuery, args, nil
+	object := fmt.Sprintf("json_object(%s)", strings.Join(pairs, ", "))
+	if relation.Many {
+		object = fmt.Sprintf("json_group_array(%s)", object)
 	}
 
-	return query, args, nil
+	return fmt.Sprintf("(SELECT %s FROM %s AS %s WHERE %s.%s = %s.%s) AS %s",
+		object, b.quoteIdentifier(relation.Table), alias,
+		alias, b.quoteIdentifier(relation.Column),
+		b.quoteIdentifier(baseTable), b.quoteIdentifier(relation.Base),
+		b.quoteIdentifier(relation.Name))
 }
 
 // BuildInsert construit une requête INSERT
@@ -172,17 +185,29 @@ func (b *SQLBuilder) buildLogicalCondition(condition LogicalCondition) (string,
 }
 
 // buildSelectClause construit la clause SELECT
-func (b *SQLBuilder) buildSelectClause(selectColumns []string) string {
+func (b *SQLBuilder) buildSelectClause(selectColumns []string, table string, relations []EmbeddedRelation) (string, error) {
 	if len(selectColumns) == 0 {
-		return "*"
+		return "*", nil
 	}
 
 	var quoted []string
+	embedded := 0
 	for _, col := range selectColumns {
-		quoted = append(quoted, b.quoteIdentifier(col))
+		switch {
+		case IsEmbeddedSelect(col):
+			if embedded >= len(relations) {
+				return "", fmt.Errorf("embedded relations are not supported here: %s", col)
+			}
+			quoted = append(quoted, b.buildEmbeddedColumn(table, relations[embedded], embedded))
+			embedded++
+		case col == "*":
+			quoted = append(quoted, b.quoteIdentifier(table)+".*")
+		default:
+			quoted = append(quoted, b.quoteIdentifier(col))
+		}
 	}
 
-	return strings.Join(quoted, ", ")
+	return strings.Join(quoted, ", "), nil
 }
 
 // buildWhereClause construit la clause WHERE avec support des conditions logiques
@@ -208,6 +233,13 @@ func (b *SQLBuilder) buildWhereClause(params *QueryParameters) (string, []interf
 		}
 	}
 
+	// Reprendre après la position du curseur
+	if len(params.After) > 0 {
+		keysetWhere, keysetArgs := b.buildKeysetCondition(params.After)
+		allConditions = append(allConditions, keysetWhere)
+		allArgs = append(allArgs, keysetArgs...)
+	}
+
 	if len(allConditions) == 0 {
 		return "", []interface{}{}
 	}
@@ -215,6 +247,81 @@ func (b *SQLBuilder) buildWhereClause(params *QueryParameters) (string, []interf
 	return fmt.Sprintf("WHERE %s", strings.Join(allConditions, " AND ")), allArgs
 }
 
+// buildKeysetCondition construit la condition des lignes situées après le curseur
+// dans l'ordre du tri. Quand toutes les colonnes vont dans le même sens avec les
+// NULL en tête et que le curseur n'en contient pas, une comparaison de row values
+// suffit : (a, b) > (?, ?). Sinon la condition est développée colonne par colonne
+// (a > ? OR (a = ? AND b < ?) ...) en tenant compte de la place des NULL.
+func (b *SQLBuilder) buildKeysetCondition(keys []CursorKey) (string, []interface{}) {
+	rowValue := true
+	for _, key := range keys {
+		if key.Value == nil || !key.nullsFirst() || key.direction() != keys[0].direction() {
+			rowValue = false
+			break
+		}
+	}
+
+	if rowValue {
+		columns := make([]string, len(keys))
+		placeholders := make([]string, len(keys))
+		args := make([]interface{}, len(keys))
+		for i, key := range keys {
+			columns[i] = b.quoteIdentifier(key.Column)
+			placeholders[i] = "?"
+			args[i] = key.Value
+		}
+		operator := ">"
+		if keys[0].direction() == "desc" {
+			operator = "<"
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), operator, strings.Join(placeholders, ", ")), args
+	}
+
+	var branches []string
+	var args []interface{}
+	var prefix []string
+	var prefixArgs []interface{}
+	for _, key := range keys {
+		column := b.quoteIdentifier(key.Column)
+		operator := ">"
+		if key.direction() == "desc" {
+			operator = "<"
+		}
+
+		// Condition « strictement après la valeur du curseur » sur cette colonne
+		var after string
+		var afterArgs []interface{}
+		switch {
+		case key.Value == nil && key.nullsFirst():
+			after = fmt.Sprintf("%s IS NOT NULL", column)
+		case key.Value == nil:
+			// NULL en fin : rien ne suit sur cette colonne
+		case key.nullsFirst():
+			after, afterArgs = fmt.Sprintf("%s %s ?", column, operator), []interface{}{key.Value}
+		default:
+			after, afterArgs = fmt.Sprintf("(%s %s ? OR %s IS NULL)", column, operator, column), []interface{}{key.Value}
+		}
+
+		if after != "" {
+			branches = append(branches, "("+strings.Join(append(append([]string{}, prefix...), after), " AND ")+")")
+			args = append(append(args, prefixArgs...), afterArgs...)
+		}
+
+		// Égalité sur cette colonne pour les colonnes suivantes
+		if key.Value == nil {
+			prefix = append(prefix, fmt.Sprintf("%s IS NULL", column))
+		} else {
+			prefix = append(prefix, fmt.Sprintf("%s = ?", column))
+			prefixArgs = append(prefixArgs, key.Value)
+		}
+	}
+
+	if len(branches) == 0 {
+		return "0", nil
+	}
+	return "(" + strings.Join(branches, " OR ") + ")", args
+}
+
 // buildWhereClauseFromFilters construit la clause WHERE depuis les filtres
 func (b *SQLBuilder) buildWhereClauseFromFilters(filters []Filter) (string, []interface{}) {
 	if len(filters) == 0 {
@@ -300,7 +407,14 @@ func (b *SQLBuilder) buildOrderClause(orderClauses []OrderClause) string {
 		if direction != "ASC" && direction != "DESC" {
 			direction = "ASC"
 		}
-		parts = append(parts, fmt.Sprintf("%s %s", b.quoteIdentifier(order.Column), direction))
+		clause := fmt.Sprintf("%s %s", b.quoteIdentifier(order.Column), direction)
+		switch order.Nulls {
+		case "first":
+			clause += " NULLS FIRST"
+		case "last":
+			clause += " NULLS LAST"
+		}
+		parts = append(parts, clause)
 	}
 
 	return fmt.Sprintf("ORDER BY %s", strings.Join(parts, ", "))
@@ -317,6 +431,10 @@ func (b *SQLBuilder) buildLimitClause(limit *int, offset *int) (string, []interf
 	}
 
 	if offset != nil {
+		// SQLite n'accepte OFFSET qu'après LIMIT ; -1 signifie sans limite
+		if limit == nil {
+			parts = append(parts, "LIMIT -1")
+		}
 		parts = append(parts, "OFFSET ?")
 		args = append(args, *offset)
 	}
@@ -333,7 +451,8 @@ func (b *SQLBuilder) quoteIdentifier(name string) string {
 
 // Executor exécute les requêtes SQL construites
 type Executor struct {
-	db *sql.DB
+	db          *sql.DB
+	jsonColumns []string // colonnes contenant du JSON à inclure tel quel
 }
 
 // NewExecutor crée un nouvel exécuteur
@@ -341,55 +460,33 @@ func NewExecutor(db *sql.DB) *Executor {
 	return &Executor{db: db}
 }
 
-// ExecuteSelect exécute une requête SELECT
-func (e *Executor) ExecuteSelect(query string, args []interface{}) (*QueryResult, error) {
-	rows, err := e.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("query execution failed: %w", err)
-	}
-	defer rows.Close()
+// SetJSONColumns déclare les colonnes dont la valeur est un document JSON (relations
+// incluses) : elles sont encodées comme objets ou tableaux et non comme chaînes
+func (e *Executor) SetJSONColumns(columns []string) {
+	e.jsonColumns = columns
+}
 
-	// Récupérer les noms de colonnes
-	columns, err := rows.Columns()
+// ExecuteSelect exécute une requête SELECT et matérialise toutes les lignes
+func (e *Executor) ExecuteSelect(query string, args []interface{}) (*QueryResult, error) {
+	reader, err := e.QuerySelect(query, args)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %w", err)
+		return nil, err
 	}
+	defer reader.Close()
 
 	// Lire les données
 	var results []map[string]interface{}
-	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
-
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		// Construire la map pour cette ligne
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-			if b, ok := val.([]byte); ok {
-				row[col] = string(b)
-			} else {
-				row[col] = val
-			}
-		}
-
-		results = append(results, row)
+	for reader.Next() {
+		results = append(results, reader.Row())
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("row iteration error: %w", err)
+	if err := reader.Err(); err != nil {
+		return nil, err
 	}
 
 	return &QueryResult{
 		Rows:    results,
-		Columns: columns,
+		Columns: reader.Columns(),
 		Count:   len(results),
 	}, nil
 }
@@ -3,6 +3,7 @@ package engine
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,6 +14,8 @@ type SchemaInfo struct {
 	Columns     []ColumnInfo              `json:"columns"`
 	ForeignKeys map[string]ForeignKeyInfo `json:"foreign_keys"`
 	Indexes     []IndexInfo               `json:"indexes"`
+	IsView      bool                      `json:"is_view"`
+	Checks      []string                  `json:"checks,omitempty"` // CHECK de niveau table
 	LastUpdated time.Time                 `json:"last_updated"`
 }
 
@@ -23,6 +26,7 @@ type ColumnInfo struct {
 	NotNull      bool   `json:"not_null"`
 	DefaultValue string `json:"default_value"`
 	IsPrimaryKey bool   `json:"is_primary_key"`
+	ColumnConstraints
 }
 
 // IndexInfo contient les informations sur un index
@@ -34,10 +38,11 @@ type IndexInfo struct {
 
 // SchemaCache gère le cache des schémas de base de données
 type SchemaCache struct {
-	db    *sql.DB
-	cache map[string]*SchemaInfo
-	mutex sync.RWMutex
-	ttl   time.Duration
+	db      *sql.DB
+	cache   map[string]*SchemaInfo
+	mutex   sync.RWMutex
+	ttl     time.Duration
+	version int64 // dernier PRAGMA schema_version observé
 }
 
 // NewSchemaCache crée un nouveau cache de schéma
@@ -94,11 +99,23 @@ func (sc *SchemaCache) loadSchemaFromDB(tableName string) (*SchemaInfo, error) {
 		LastUpdated: time.Now(),
 	}
 
+	var objectType string
+	var ddl sql.NullString
+	if err := sc.db.QueryRow("SELECT type, sql FROM sqlite_master WHERE name = ?", tableName).Scan(&objectType, &ddl); err != nil {
+		return nil, fmt.Errorf("table %s not found: %w", tableName, err)
+	}
+	schema.IsView = objectType == "view"
+
 	// Charger les colonnes
 	if err := sc.loadColumns(schema); err != nil {
 		return nil, err
 	}
 
+	// Contraintes déclarées dans le DDL (CHECK, COLLATE, colonnes générées)
+	if !schema.IsView && ddl.Valid {
+		sc.applyDefinition(schema, parseCreateTable(ddl.String))
+	}
+
 	// Charger les clés étrangères
 	if err := sc.loadForeignKeys(schema); err != nil {
 		return nil, err
@@ -114,7 +131,8 @@ func (sc *SchemaCache) loadSchemaFromDB(tableName string) (*SchemaInfo, error) {
 
 // loadColumns charge les informations des colonnes
 func (sc *SchemaCache) loadColumns(schema *SchemaInfo) error {
-	query := fmt.Sprintf("PRAGMA table_info(%s)", schema.TableName)
+	// table_xinfo inclut les colonnes générées (hidden = 2 ou 3)
+	query := fmt.Sprintf("PRAGMA table_xinfo(%s)", schema.TableName)
 	rows, err := sc.db.Query(query)
 	if err != nil {
 		return fmt.Errorf("failed to get table info: %w", err)
@@ -127,11 +145,17 @@ func (sc *SchemaCache) loadColumns(schema *SchemaInfo) error {
 		var notNull int
 		var defaultValue interface{}
 		var pk int
+		var hidden int
 
-		if err := rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk); err != nil {
+		if err := rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk, &hidden); err != nil {
 			return fmt.Errorf("failed to scan column info: %w", err)
 		}
 
+		// Colonnes cachées des tables virtuelles
+		if hidden == 1 {
+			continue
+		}
+
 		column := ColumnInfo{
 			Name:         name,
 			Type:         dataType,
@@ -139,6 +163,8 @@ func (sc *SchemaCache) loadColumns(schema *SchemaInfo) error {
 			IsPrimaryKey: pk == 1,
 		}
 
+		column.Generated = hidden == 2 || hidden == 3
+
 		if defaultValue != nil {
 			column.DefaultValue = fmt.Sprintf("%v", defaultValue)
 		}
@@ -149,6 +175,37 @@ func (sc *SchemaCache) loadColumns(schema *SchemaInfo) error {
 	return nil
 }
 
+// applyDefinition reporte les contraintes du CREATE TABLE sur les colonnes. Les
+// CHECK de niveau table restent dans schema.Checks ; leurs conditions simples
+// (IN, bornes, longueurs) sont appliquées aux colonnes qu'elles référencent.
+func (sc *SchemaCache) applyDefinition(schema *SchemaInfo, def *tableDefinition) {
+	schema.Checks = def.checks
+
+	columns := make(map[string]*ColumnConstraints, len(schema.Columns))
+	for i := range schema.Columns {
+		column := &schema.Columns[i]
+		columns[strings.ToLower(column.Name)] = &column.ColumnConstraints
+
+		parsed, exists := def.columns[strings.ToLower(column.Name)]
+		if !exists {
+			continue
+		}
+		column.Collation = parsed.Collation
+		column.Checks = parsed.Checks
+		column.Expression = parsed.Expression
+		column.Generated = column.Generated || parsed.Generated
+	}
+
+	for _, column := range schema.Columns {
+		for _, check := range column.Checks {
+			applyCheck(check, columns)
+		}
+	}
+	for _, check := range schema.Checks {
+		applyCheck(check, columns)
+	}
+}
+
 // loadForeignKeys charge les clés étrangères
 func (sc *SchemaCache) loadForeignKeys(schema *SchemaInfo) error {
 	query := fmt.Sprintf("PRAGMA foreign_key_list(%s)", schema.TableName)
@@ -243,6 +300,45 @@ func (sc *SchemaCache) getIndexColumns(indexName string) ([]string, error) {
 	return columns, nil
 }
 
+// ListTables retourne les tables et vues exposées, hors tables internes
+// (préfixe "_") et tables système SQLite
+func (sc *SchemaCache) ListTables() ([]string, error) {
+	rows, err := sc.db.Query(`SELECT name FROM sqlite_master
+		WHERE type IN ('table', 'view') AND name NOT LIKE '\_%' ESCAPE '\' AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// SchemaVersion retourne la version du schéma (PRAGMA schema_version) et
+// vide le cache quand elle a changé depuis le dernier appel
+func (sc *SchemaCache) SchemaVersion() (int64, error) {
+	var version int64
+	if err := sc.db.QueryRow("PRAGMA schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if version != sc.version {
+		sc.cache = make(map[string]*SchemaInfo)
+		sc.version = version
+	}
+	return version, nil
+}
+
 // InvalidateCache invalide le cache pour une table spécifique
 func (sc *SchemaCache) InvalidateCache(tableName string) {
 	sc.mutex.Lock()
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CountMode est le mode de comptage demandé par l'en-tête Prefer: count=...
+type CountMode string
+
+const (
+	CountNone      CountMode = ""
+	CountExact     CountMode = "exact"     // COUNT(*) avec les mêmes filtres et politiques
+	CountPlanned   CountMode = "planned"   // estimation à partir de sqlite_stat1 (ANALYZE)
+	CountEstimated CountMode = "estimated" // exact sous le seuil, estimé au-dessus
+)
+
+// DefaultEstimatedCountThreshold est le seuil par défaut du mode estimated
+const DefaultEstimatedCountThreshold = 10000
+
+// CountThreshold retourne le nombre de lignes estimées en dessous duquel le mode
+// estimated compte exactement
+func (c PaginationConfig) CountThreshold() int64 {
+	if c.EstimatedCountThreshold > 0 {
+		return c.EstimatedCountThreshold
+	}
+	return DefaultEstimatedCountThreshold
+}
+
+// ParseCountPreference lit le mode de comptage d'un en-tête Prefer
+// (ex: "count=exact, tx=rollback")
+func ParseCountPreference(prefer string) CountMode {
+	for _, preference := range strings.Split(prefer, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(preference), "=")
+		if !found || !strings.EqualFold(name, "count") {
+			continue
+		}
+		switch mode := CountMode(strings.ToLower(strings.TrimSpace(value))); mode {
+		case CountExact, CountPlanned, CountEstimated:
+			return mode
+		}
+	}
+	return CountNone
+}
+
+// BuildCount construit le comptage des lignes d'une lecture : mêmes filtres, sans
+// tri, pagination ni curseur. Les politiques s'appliquent ensuite comme pour le SELECT.
+func (b *SQLBuilder) BuildCount(params *QueryParameters) (string, []interface{}, error) {
+	countParams := *params
+	countParams.Order = nil
+	countParams.Limit = nil
+	countParams.Offset = nil
+	countParams.After = nil
+
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", b.quoteIdentifier(params.Table))
+	whereClause, args := b.buildWhereClause(&countParams)
+	if whereClause != "" {
+		query += " " + whereClause
+	}
+	return query, args, nil
+}
+
+// ExecuteCount exécute une requête qui retourne un seul entier (COUNT(*))
+func (e *Executor) ExecuteCount(query string, args []interface{}) (int64, error) {
+	var count int64
+	if err := e.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count execution failed: %w", err)
+	}
+	return count, nil
+}
+
+// EstimateCount estime le nombre de lignes d'une lecture à partir des statistiques
+// de sqlite_stat1, sans parcourir la table. La taille de la table est réduite par
+// la sélectivité de chaque filtre : nombre moyen de lignes par valeur pour une
+// égalité sur la première colonne d'un index, heuristiques de SQLite sinon.
+// Retourne false si la table n'a pas été analysée (ANALYZE).
+func (e *Executor) EstimateCount(params *QueryParameters) (int64, bool, error) {
+	stats, err := e.tableStats(params.Table)
+	if err != nil || stats == nil {
+		return 0, false, err
+	}
+
+	estimate := float64(stats.rows)
+	for _, filter := range params.Filters {
+		estimate *= stats.selectivity(filter)
+	}
+	for range params.Conditions {
+		estimate *= 0.5
+	}
+
+	return int64(math.Round(estimate)), true, nil
+}
+
+// tableStats contient les statistiques d'une table issues de sqlite_stat1
+type tableStats struct {
+	rows        int64
+	rowsPerKey  map[string]float64 // première colonne d'index -> lignes par valeur
+	rowIDColumn string             // alias du rowid (INTEGER PRIMARY KEY)
+}
+
+// tableStats lit sqlite_stat1 et les index d'une table ; nil sans statistiques
+func (e *Executor) tableStats(table string) (*tableStats, error) {
+	var exists int
+	if err := e.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_stat1'").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to read statistics: %w", err)
+	}
+	if exists == 0 {
+		return nil, nil
+	}
+
+	rows, err := e.db.Query("SELECT idx, stat FROM sqlite_stat1 WHERE tbl = ?", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read statistics: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &tableStats{rows: -1, rowsPerKey: make(map[string]float64)}
+	indexes := make(map[string][]string)
+	for rows.Next() {
+		var index sql.NullString
+		var stat string
+		if err := rows.Scan(&index, &stat); err != nil {
+			return nil, fmt.Errorf("failed to read statistics: %w", err)
+		}
+		fields := strings.Fields(stat)
+		if len(fields) == 0 {
+			continue
+		}
+		if count, err := strconv.ParseInt(fields[0], 10, 64); err == nil && count > stats.rows {
+			stats.rows = count
+		}
+		if index.Valid {
+			indexes[index.String] = fields
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read statistics: %w", err)
+	}
+	if stats.rows < 0 {
+		return nil, nil
+	}
+
+	for index, fields := range indexes {
+		if len(fields) < 2 {
+			continue
+		}
+		perKey, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		var column string
+		if err := e.db.QueryRow("SELECT name FROM pragma_index_info(?) WHERE seqno = 0", index).Scan(&column); err != nil {
+			continue
+		}
+		key := strings.ToLower(column)
+		if current, exists := stats.rowsPerKey[key]; !exists || perKey < current {
+			stats.rowsPerKey[key] = perKey
+		}
+	}
+
+	// Alias du rowid : clé primaire d'une seule colonne INTEGER
+	var primaryKeys int
+	var rowID, rowIDType sql.NullString
+	err = e.db.QueryRow("SELECT COUNT(*), MAX(name), MAX(type) FROM pragma_table_info(?) WHERE pk > 0", table).Scan(&primaryKeys, &rowID, &rowIDType)
+	if err == nil && primaryKeys == 1 && strings.EqualFold(rowIDType.String, "INTEGER") {
+		stats.rowIDColumn = strings.ToLower(rowID.String)
+	}
+
+	return stats, nil
+}
+
+// selectivity retourne la fraction des lignes estimée retenue par un filtre
+func (s *tableStats) selectivity(filter Filter) float64 {
+	if s.rows == 0 {
+		return 1
+	}
+
+	column := strings.ToLower(filter.Column)
+	equality := 0.1
+	switch {
+	case column != "" && column == s.rowIDColumn:
+		equality = 1 / float64(s.rows)
+	case s.rowsPerKey[column] > 0:
+		equality = s.rowsPerKey[column] / float64(s.rows)
+	}
+
+	switch filter.Operator {
+	case OpEqual, OpIs:
+		return equality
+	case OpIn:
+		return math.Min(1, equality*float64(len(strings.Split(filter.Value, ","))))
+	case OpNotEqual, OpNot:
+		return 1 - equality
+	case OpGreaterThan, OpGreaterEqual, OpLessThan, OpLessEqual:
+		// SQLite estime qu'une borne divise le parcours par 4
+		return 0.25
+	case OpLike, OpILike:
+		return 0.5
+	}
+	return equality
+}
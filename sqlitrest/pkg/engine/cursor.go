@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PaginationConfig configure la pagination par curseur et le comptage des lignes
+type PaginationConfig struct {
+	CursorSecret            string `toml:"cursor_secret"`             // clé HMAC des curseurs ; aléatoire au démarrage si vide
+	EstimatedCountThreshold int64  `toml:"estimated_count_threshold"` // Prefer: count=estimated, défaut 10000
+	StreamThreshold         int    `toml:"stream_threshold"`          // lignes au-delà desquelles une lecture sans limite est streamée, défaut 1000
+}
+
+// CursorKey est une colonne du tri et sa valeur dans la dernière ligne lue :
+// la page suivante reprend strictement après cette position
+type CursorKey struct {
+	OrderClause
+	Value interface{}
+}
+
+// CursorCodec encode et vérifie les curseurs opaques de pagination. Un curseur
+// contient les valeurs des colonnes du tri (clé primaire comprise) de la dernière
+// ligne d'une page, la table et le tri, signés par HMAC-SHA256.
+type CursorCodec struct {
+	secret []byte
+}
+
+// cursorPayload est le contenu signé d'un curseur
+type cursorPayload struct {
+	Table  string        `json:"t"`
+	Order  string        `json:"o"`
+	Values []interface{} `json:"v"`
+}
+
+// NewCursorCodec crée un codec ; sans secret configuré, une clé aléatoire est
+// tirée et les curseurs ne survivent pas à un redémarrage
+func NewCursorCodec(config PaginationConfig) (*CursorCodec, error) {
+	secret := []byte(config.CursorSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate cursor secret: %w", err)
+		}
+	}
+	return &CursorCodec{secret: secret}, nil
+}
+
+// KeysetOrder complète le tri avec les colonnes de la clé primaire absentes pour
+// obtenir un ordre total, condition d'une pagination par curseur stable
+func KeysetOrder(order []OrderClause, primaryKey []string) []OrderClause {
+	keyset := append([]OrderClause{}, order...)
+	for _, column := range primaryKey {
+		present := false
+		for _, clause := range order {
+			if strings.EqualFold(clause.Column, column) {
+				present = true
+				break
+			}
+		}
+		if !present {
+			keyset = append(keyset, OrderClause{Column: column, Direction: "asc"})
+		}
+	}
+	return keyset
+}
+
+// Encode retourne le curseur qui reprend après la ligne donnée ; false si la
+// ligne ne contient pas toutes les colonnes du tri (select trop restreint)
+func (c *CursorCodec) Encode(table string, order []OrderClause, row map[string]interface{}) (string, bool) {
+	values := make([]interface{}, len(order))
+	for i, clause := range order {
+		value, present := lookup(row, clause.Column)
+		if !present {
+			return "", false
+		}
+		values[i] = value
+	}
+
+	payload, err := json.Marshal(cursorPayload{Table: table, Order: orderSignature(order), Values: values})
+	if err != nil {
+		return "", false
+	}
+
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(c.sign(payload)), true
+}
+
+// Decode vérifie la signature d'un curseur et retourne la position de reprise.
+// Le curseur doit avoir été émis pour la même table et le même tri.
+func (c *CursorCodec) Decode(cursor, table string, order []OrderClause) ([]CursorKey, error) {
+	encoding := base64.RawURLEncoding
+	encodedPayload, encodedSignature, found := strings.Cut(cursor, ".")
+	if !found {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	signature, err := encoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return nil, fmt.Errorf("invalid cursor signature")
+	}
+
+	var decoded cursorPayload
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	if decoded.Table != table || decoded.Order != orderSignature(order) || len(decoded.Values) != len(order) {
+		return nil, fmt.Errorf("cursor does not match the table and order of this request")
+	}
+
+	keys := make([]CursorKey, len(order))
+	for i, clause := range order {
+		keys[i] = CursorKey{OrderClause: clause, Value: cursorValue(decoded.Values[i])}
+	}
+	return keys, nil
+}
+
+// sign calcule la signature HMAC-SHA256 d'un contenu de curseur
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// orderSignature décrit un tri sous la forme du paramètre order (name.desc.nullslast,id.asc)
+func orderSignature(order []OrderClause) string {
+	parts := make([]string, len(order))
+	for i, clause := range order {
+		parts[i] = strings.ToLower(clause.Column) + "." + clause.direction()
+		if clause.Nulls != "" {
+			parts[i] += ".nulls" + clause.Nulls
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursorValue restaure une valeur décodée : entier si possible pour ne pas
+// perdre la précision des grands identifiants
+func cursorValue(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	f, _ := number.Float64()
+	return f
+}
+
+// direction retourne la direction normalisée du tri (asc ou desc)
+func (o OrderClause) direction() string {
+	if strings.EqualFold(o.Direction, "desc") {
+		return "desc"
+	}
+	return "asc"
+}
+
+// nullsFirst indique où se placent les NULL : en tête par défaut en ordre
+// croissant et en fin en ordre décroissant, comme SQLite
+func (o OrderClause) nullsFirst() bool {
+	switch o.Nulls {
+	case "first":
+		return true
+	case "last":
+		return false
+	}
+	return o.direction() == "asc"
+}
@@ -0,0 +1,495 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ColumnConstraints contient les contraintes d'une colonne extraites du DDL
+type ColumnConstraints struct {
+	Generated        bool          `json:"generated,omitempty"` // GENERATED ALWAYS AS (...)
+	Expression       string        `json:"expression,omitempty"`
+	Collation        string        `json:"collation,omitempty"`
+	Checks           []string      `json:"checks,omitempty"` // expressions CHECK déclarées sur la colonne
+	Enum             []interface{} `json:"enum,omitempty"`   // CHECK (col IN (...))
+	Minimum          *float64      `json:"minimum,omitempty"`
+	Maximum          *float64      `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64      `json:"exclusive_minimum,omitempty"`
+	ExclusiveMaximum *float64      `json:"exclusive_maximum,omitempty"`
+	MinLength        *int          `json:"min_length,omitempty"` // CHECK (length(col) >= n)
+	MaxLength        *int          `json:"max_length,omitempty"`
+}
+
+// ddlToken est un token significatif du DDL avec sa position dans le texte
+type ddlToken struct {
+	text   string
+	quoted bool // identifiant entre guillemets ou littéral chaîne
+	str    bool // littéral chaîne '...'
+	start  int
+	end    int
+}
+
+// upper retourne le mot-clé en majuscules (vide pour un identifiant quoté)
+func (t ddlToken) upper() string {
+	if t.quoted {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// name retourne l'identifiant sans guillemets
+func (t ddlToken) name() string {
+	if t.quoted && !t.str && len(t.text) >= 2 {
+		closer := t.text[len(t.text)-1:]
+		return strings.ReplaceAll(t.text[1:len(t.text)-1], closer+closer, closer)
+	}
+	return t.text
+}
+
+// tokenizeDDL découpe un DDL en tokens significatifs (sans espaces ni commentaires)
+func tokenizeDDL(sql string) []ddlToken {
+	var tokens []ddlToken
+	i, n := 0, len(sql)
+
+	for i < n {
+		c := sql[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '-' && i+1 < n && sql[i+1] == '-':
+			for i < n && sql[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < n && sql[i+1] == '*':
+			if end := strings.Index(sql[i+2:], "*/"); end == -1 {
+				i = n
+			} else {
+				i += end + 4
+			}
+			continue
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			i++
+			for i < n {
+				if sql[i] == closer {
+					if closer != ']' && i+1 < n && sql[i+1] == closer {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, ddlToken{text: sql[start:i], quoted: true, str: c == '\'', start: start, end: i})
+			continue
+		case isIdentStart(c):
+			for i < n && (isIdentStart(sql[i]) || (sql[i] >= '0' && sql[i] <= '9') || sql[i] == '$') {
+				i++
+			}
+		case c >= '0' && c <= '9' || (c == '.' && i+1 < n && sql[i+1] >= '0' && sql[i+1] <= '9'):
+			for i < n && (isIdentStart(sql[i]) || (sql[i] >= '0' && sql[i] <= '9') || sql[i] == '.' ||
+				((sql[i] == '+' || sql[i] == '-') && (sql[i-1] == 'e' || sql[i-1] == 'E'))) {
+				i++
+			}
+		default:
+			i++
+			// Opérateurs de deux caractères
+			if i < n {
+				switch sql[start : i+1] {
+				case ">=", "<=", "<>", "!=", "==", "||":
+					i++
+				}
+			}
+		}
+		tokens = append(tokens, ddlToken{text: sql[start:i], start: start, end: i})
+	}
+
+	return tokens
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// columnConstraintKeywords terminent le type d'une colonne
+var columnConstraintKeywords = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "NOT": true, "NULL": true, "UNIQUE": true,
+	"CHECK": true, "DEFAULT": true, "COLLATE": true, "REFERENCES": true,
+	"GENERATED": true, "AS": true,
+}
+
+// tableDefinition est le résultat de l'analyse d'un CREATE TABLE
+type tableDefinition struct {
+	columns map[string]*ColumnConstraints // nom de colonne (minuscules) -> contraintes
+	checks  []string                      // CHECK de niveau table
+}
+
+// parseCreateTable extrait les contraintes d'un CREATE TABLE
+func parseCreateTable(sql string) *tableDefinition {
+	def := &tableDefinition{columns: make(map[string]*ColumnConstraints)}
+	tokens := tokenizeDDL(sql)
+
+	// Corps entre la première parenthèse et sa fermante
+	open := -1
+	for i, tok := range tokens {
+		if tok.text == "(" {
+			open = i
+			break
+		}
+	}
+	if open == -1 {
+		return def // CREATE TABLE ... AS SELECT
+	}
+	closeAt := matchingParen(tokens, open)
+
+	// Définitions séparées par les virgules de premier niveau
+	start := open + 1
+	depth := 0
+	for i := open + 1; i <= closeAt && i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if (tokens[i].text == "," && depth == 0) || i == closeAt {
+			if i > start {
+				def.parseDefinition(sql, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+
+	return def
+}
+
+// parseDefinition analyse une définition de colonne ou une contrainte de table
+func (def *tableDefinition) parseDefinition(sql string, tokens []ddlToken) {
+	i := 0
+	if tokens[0].upper() == "CONSTRAINT" {
+		i = 2
+	}
+	if i >= len(tokens) {
+		return
+	}
+
+	switch tokens[i].upper() {
+	case "PRIMARY", "UNIQUE", "FOREIGN":
+		return
+	case "CHECK":
+		if expr, _, ok := parenExpression(sql, tokens, i+1); ok {
+			def.checks = append(def.checks, expr)
+		}
+		return
+	}
+
+	column := &ColumnConstraints{}
+	def.columns[strings.ToLower(tokens[0].name())] = column
+
+	// Passer le type
+	i = 1
+	for i < len(tokens) && !columnConstraintKeywords[tokens[i].upper()] {
+		if tokens[i].text == "(" {
+			i = matchingParen(tokens, i)
+		}
+		i++
+	}
+
+	for i < len(tokens) {
+		switch tokens[i].upper() {
+		case "CHECK":
+			expr, next, ok := parenExpression(sql, tokens, i+1)
+			if !ok {
+				return
+			}
+			column.Checks = append(column.Checks, expr)
+			i = next
+		case "COLLATE":
+			if i+1 < len(tokens) {
+				column.Collation = tokens[i+1].name()
+			}
+			i += 2
+		case "AS":
+			expr, next, ok := parenExpression(sql, tokens, i+1)
+			if !ok {
+				return
+			}
+			column.Generated = true
+			column.Expression = expr
+			i = next
+		case "DEFAULT":
+			// La valeur par défaut vient de PRAGMA table_info ; passer l'expression
+			i++
+			if i < len(tokens) && tokens[i].text == "(" {
+				i = matchingParen(tokens, i)
+			} else if i < len(tokens) && (tokens[i].text == "-" || tokens[i].text == "+") {
+				i++
+			}
+			i++
+		default:
+			if tokens[i].text == "(" {
+				i = matchingParen(tokens, i)
+			}
+			i++
+		}
+	}
+}
+
+// matchingParen retourne l'index de la parenthèse fermante correspondant à open
+func matchingParen(tokens []ddlToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// parenExpression retourne le texte entre parenthèses commençant au token open
+func parenExpression(sql string, tokens []ddlToken, open int) (string, int, bool) {
+	if open >= len(tokens) || tokens[open].text != "(" {
+		return "", open, false
+	}
+	closeAt := matchingParen(tokens, open)
+	if tokens[closeAt].text != ")" || closeAt == open+1 {
+		return "", closeAt + 1, false
+	}
+	return strings.TrimSpace(sql[tokens[open].end:tokens[closeAt].start]), closeAt + 1, true
+}
+
+// applyCheck interprète une expression CHECK et enrichit les contraintes des
+// colonnes concernées : IN (...) devient une énumération, les comparaisons avec
+// une constante des bornes, length(col) des longueurs. Les conditions reliées par
+// OR ou plus complexes sont ignorées.
+func applyCheck(expr string, columns map[string]*ColumnConstraints) {
+	for _, conjunct := range splitConjuncts(tokenizeDDL(expr)) {
+		applyConjunct(conjunct, columns)
+	}
+}
+
+// splitConjuncts découpe une expression en termes reliés par AND de premier niveau.
+// Une expression contenant un OR de premier niveau n'est pas découpée.
+func splitConjuncts(tokens []ddlToken) [][]ddlToken {
+	// Parenthèses englobantes : (a AND b)
+	for len(tokens) > 2 && tokens[0].text == "(" && matchingParen(tokens, 0) == len(tokens)-1 {
+		tokens = tokens[1 : len(tokens)-1]
+	}
+
+	var conjuncts [][]ddlToken
+	depth, start := 0, 0
+	between := false
+	for i, tok := range tokens {
+		switch {
+		case tok.text == "(":
+			depth++
+		case tok.text == ")":
+			depth--
+		case depth > 0:
+		case tok.upper() == "OR":
+			return nil
+		case tok.upper() == "BETWEEN":
+			between = true
+		case tok.upper() == "AND":
+			if between {
+				between = false
+				continue
+			}
+			conjuncts = append(conjuncts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	return append(conjuncts, tokens[start:])
+}
+
+// applyConjunct reconnaît un terme simple portant sur une colonne
+func applyConjunct(tokens []ddlToken, columns map[string]*ColumnConstraints) {
+	for len(tokens) > 2 && tokens[0].text == "(" && matchingParen(tokens, 0) == len(tokens)-1 {
+		tokens = tokens[1 : len(tokens)-1]
+	}
+	if len(tokens) < 3 {
+		return
+	}
+
+	// length(col) ... : contraintes de longueur
+	length := false
+	subject := tokens[0]
+	rest := tokens[1:]
+	if strings.EqualFold(tokens[0].text, "length") && len(tokens) >= 6 && tokens[1].text == "(" && tokens[3].text == ")" {
+		length = true
+		subject = tokens[2]
+		rest = tokens[4:]
+	}
+
+	column, exists := columns[strings.ToLower(subject.name())]
+	if !exists || (subject.quoted && subject.str) {
+		// Forme inversée : constante op colonne
+		if !length && len(tokens) == 3 {
+			if c, ok := columns[strings.ToLower(tokens[2].name())]; ok && !tokens[2].str {
+				if value, ok := numericLiteral(tokens[:1]); ok {
+					applyBound(c, invertOperator(tokens[1].text), value, false)
+				}
+			}
+		}
+		return
+	}
+
+	switch rest[0].upper() {
+	case "IN":
+		if length || len(rest) < 3 || rest[1].text != "(" || rest[len(rest)-1].text != ")" {
+			return
+		}
+		var values []interface{}
+		items := rest[2 : len(rest)-1]
+		for j := 0; j < len(items); j++ {
+			value, width, ok := literalAt(items, j)
+			if !ok {
+				return
+			}
+			values = append(values, value)
+			j += width
+			if j < len(items) && items[j].text != "," {
+				return
+			}
+		}
+		column.Enum = values
+	case "BETWEEN":
+		// col BETWEEN a AND b
+		for j := 1; j < len(rest); j++ {
+			if rest[j].upper() == "AND" {
+				low, okLow := numericLiteral(rest[1:j])
+				high, okHigh := numericLiteral(rest[j+1:])
+				if okLow && okHigh {
+					applyBound(column, ">=", low, length)
+					applyBound(column, "<=", high, length)
+				}
+				return
+			}
+		}
+	default:
+		if value, ok := numericLiteral(rest[1:]); ok {
+			applyBound(column, rest[0].text, value, length)
+		}
+	}
+}
+
+// applyBound enregistre une borne (valeur ou longueur) en gardant la plus stricte
+func applyBound(column *ColumnConstraints, operator string, value float64, length bool) {
+	tighter := func(current **float64, candidate float64, lower bool) {
+		if *current == nil || (lower && candidate > **current) || (!lower && candidate < **current) {
+			*current = &candidate
+		}
+	}
+	tighterInt := func(current **int, candidate int, lower bool) {
+		if *current == nil || (lower && candidate > **current) || (!lower && candidate < **current) {
+			*current = &candidate
+		}
+	}
+
+	if length {
+		n := int(value)
+		switch operator {
+		case ">=":
+			tighterInt(&column.MinLength, n, true)
+		case ">":
+			tighterInt(&column.MinLength, n+1, true)
+		case "<=":
+			tighterInt(&column.MaxLength, n, false)
+		case "<":
+			tighterInt(&column.MaxLength, n-1, false)
+		case "=", "==":
+			tighterInt(&column.MinLength, n, true)
+			tighterInt(&column.MaxLength, n, false)
+		}
+		return
+	}
+
+	switch operator {
+	case ">=":
+		tighter(&column.Minimum, value, true)
+	case ">":
+		tighter(&column.ExclusiveMinimum, value, true)
+	case "<=":
+		tighter(&column.Maximum, value, false)
+	case "<":
+		tighter(&column.ExclusiveMaximum, value, false)
+	}
+}
+
+// invertOperator retourne l'opérateur équivalent quand les opérandes sont inversés
+func invertOperator(operator string) string {
+	switch operator {
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	}
+	return operator
+}
+
+// numericLiteral lit une constante numérique éventuellement signée
+func numericLiteral(tokens []ddlToken) (float64, bool) {
+	sign := 1.0
+	if len(tokens) == 2 && (tokens[0].text == "-" || tokens[0].text == "+") {
+		if tokens[0].text == "-" {
+			sign = -1
+		}
+		tokens = tokens[1:]
+	}
+	if len(tokens) != 1 || tokens[0].quoted {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(tokens[0].text, 64)
+	if err != nil {
+		return 0, false
+	}
+	return sign * value, true
+}
+
+// literalAt lit un littéral (chaîne, nombre, NULL) et retourne le nombre de tokens consommés
+func literalAt(tokens []ddlToken, i int) (interface{}, int, bool) {
+	tok := tokens[i]
+	switch {
+	case tok.str:
+		return strings.ReplaceAll(tok.text[1:len(tok.text)-1], "''", "'"), 1, true
+	case tok.upper() == "NULL":
+		return nil, 1, true
+	case tok.text == "-" || tok.text == "+":
+		if i+1 < len(tokens) {
+			if value, ok := numericLiteral(tokens[i : i+2]); ok {
+				return numberValue(value), 2, true
+			}
+		}
+	default:
+		if value, ok := numericLiteral(tokens[i : i+1]); ok {
+			return numberValue(value), 1, true
+		}
+	}
+	return nil, 0, false
+}
+
+// numberValue retourne un int64 pour les valeurs entières, float64 sinon
+func numberValue(value float64) interface{} {
+	if value == float64(int64(value)) {
+		return int64(value)
+	}
+	return value
+}
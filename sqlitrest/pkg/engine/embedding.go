@@ -16,139 +16,150 @@ func NewResourceEmbedding(db *sql.DB) *ResourceEmbedding {
 	return &ResourceEmbedding{db: db}
 }
 
-// EmbeddedRelation représente une relation à inclure
+// EmbeddedRelation représente une relation à inclure, nommée d'après sa table
+// (select=*,users(name) ou select=*,comments(*)).
+// Une relation directe (la table de base référence la table incluse) produit un
+// objet ; une relation inverse (la table incluse référence la table de base) un tableau.
 type EmbeddedRelation struct {
-	Name     string
-	Table    string
-	Column   string
-	Alias    string
-	Required bool
+	Name    string   // table incluse, telle qu'écrite dans select
+	Columns []string // colonnes demandées, "*" développé à la résolution
+	Table   string   // table incluse, telle que déclarée dans le schéma
+	Column  string   // colonne de la table incluse utilisée pour la jointure
+	Base    string   // colonne de la table de base utilisée pour la jointure
+	Many    bool     // relation inverse : plusieurs lignes par ligne de base
 }
 
-// ParseEmbeddedRelations parse les relations depuis le paramètre select
-func ParseEmbeddedRelations(selectParam string) ([]EmbeddedRelation, error) {
-	if selectParam == "*" {
-		return nil, nil
-	}
+// IsEmbeddedSelect indique si un élément de select est une relation (nom(colonnes))
+func IsEmbeddedSelect(item string) bool {
+	open := strings.Index(item, "(")
+	return open > 0 && strings.HasSuffix(item, ")")
+}
 
-	parts := strings.Split(selectParam, ",")
+// ParseEmbeddedRelations extrait les relations des éléments de select ; les
+// colonnes simples sont ignorées
+func ParseEmbeddedRelations(selectItems []string) ([]EmbeddedRelation, error) {
 	var relations []EmbeddedRelation
 
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
+	for _, item := range selectItems {
+		if !IsEmbeddedSelect(item) {
+			if strings.ContainsAny(item, "()") {
+				return nil, fmt.Errorf("invalid select item: %s", item)
+			}
+			continue
+		}
 
-		// Format: relation_name(columns) ou relation_name
-		if strings.Contains(part, "(") && strings.Contains(part, ")") {
-			// Format avec colonnes spécifiques: posts(title,created_at)
-			relationName := part[:strings.Index(part, "(")]
-			columnsPart := strings.Trim(part[strings.Index(part, "(")+1:strings.Index(part, ")")], "()")
+		open := strings.Index(item, "(")
+		name := strings.TrimSpace(item[:open])
+		columnsPart := item[open+1 : len(item)-1]
+		if strings.ContainsAny(columnsPart, "()") {
+			return nil, fmt.Errorf("nested embedding is not supported: %s", item)
+		}
 
-			columns := strings.Split(columnsPart, ",")
-			for i, col := range columns {
-				columns[i] = strings.TrimSpace(col)
+		var columns []string
+		for _, column := range strings.Split(columnsPart, ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				columns = append(columns, column)
 			}
-
-			relations = append(relations, EmbeddedRelation{
-				Name:  relationName,
-				Alias: relationName,
-			})
-		} else if strings.Contains(part, ".") {
-			// Format imbriqué: user.profile ou posts.comments.user
-			relations = append(relations, EmbeddedRelation{
-				Name:  part,
-				Alias: part,
-			})
-		} else if part != "*" {
-			// Relation simple: posts
-			relations = append(relations, EmbeddedRelation{
-				Name:  part,
-				Alias: part,
-			})
 		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("no columns selected for embedded relation %s", name)
+		}
+
+		relations = append(relations, EmbeddedRelation{
+			Name:    name,
+			Columns: columns,
+		})
 	}
 
 	return relations, nil
 }
 
-// BuildEmbeddedQuery construit une requête avec JOINs pour les relations
-func (e *ResourceEmbedding) BuildEmbeddedQuery(baseQuery string, relations []EmbeddedRelation, baseTable string) (string, error) {
-	if len(relations) == 0 {
-		return baseQuery, nil
+// ResolveRelations associe chaque relation de select à une clé étrangère entre la
+// table de base et la table incluse, dans un sens ou dans l'autre, et vérifie les
+// colonnes demandées. Une relation absente ou ambiguë est une erreur.
+func (e *ResourceEmbedding) ResolveRelations(params *QueryParameters) ([]EmbeddedRelation, error) {
+	relations, err := ParseEmbeddedRelations(params.Select)
+	if err != nil || len(relations) == 0 {
+		return nil, err
 	}
 
-	// Analyser les foreign keys de la table de base
-	foreignKeys, err := e.GetForeignKeys(baseTable)
+	baseKeys, err := e.GetForeignKeys(params.Table)
 	if err != nil {
-		return baseQuery, fmt.Errorf("failed to analyze foreign keys: %w", err)
+		return nil, err
 	}
 
-	// Construire les JOINs
-	var joinClauses []string
-	var selectColumns []string
-
-	// Ajouter les colonnes de la table de base
-	selectColumns = append(selectColumns, fmt.Sprintf("%s.*", baseTable))
+	for i := range relations {
+		relation := &relations[i]
+
+		var candidates []EmbeddedRelation
+		for _, fk := range baseKeys {
+			if strings.EqualFold(fk.PrimaryTable, relation.Name) {
+				candidates = append(candidates, EmbeddedRelation{
+					Table:  fk.PrimaryTable,
+					Column: fk.PrimaryColumn,
+					Base:   fk.ForeignColumn,
+				})
+			}
+		}
 
-	for _, relation := range relations {
-		fkInfo, exists := foreignKeys[relation.Name]
-		if !exists {
-			// Pour l'instant, ignorer les relations qui ne sont pas des foreign keys directs
-			continue
+		relatedKeys, err := e.GetForeignKeys(relation.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, fk := range relatedKeys {
+			if strings.EqualFold(fk.PrimaryTable, params.Table) {
+				candidates = append(candidates, EmbeddedRelation{
+					Column: fk.ForeignColumn,
+					Base:   fk.PrimaryColumn,
+					Many:   true,
+				})
+			}
 		}
 
-		// Relation directe : LEFT JOIN related_table ON base_table.fk_column = related_table.id
-		joinClause := fmt.Sprintf("LEFT JOIN %s ON %s.%s = %s.%s",
-			fkInfo.PrimaryTable, baseTable, fkInfo.ForeignColumn, fkInfo.PrimaryTable, fkInfo.PrimaryColumn)
-		joinClauses = append(joinClauses, joinClause)
+		switch len(candidates) {
+		case 0:
+			return nil, fmt.Errorf("could not find a relationship between %s and %s", params.Table, relation.Name)
+		case 1:
+		default:
+			return nil, fmt.Errorf("more than one relationship between %s and %s", params.Table, relation.Name)
+		}
 
-		// Ajouter colonnes de la relation
-		selectColumns = append(selectColumns, fmt.Sprintf("%s as %s",
-			fmt.Sprintf("%s.*", fkInfo.PrimaryTable), relation.Name))
-	}
+		columns, err := e.tableColumns(relation.Name)
+		if err != nil {
+			return nil, err
+		}
+		found := candidates[0]
+		relation.Column, relation.Base, relation.Many = found.Column, found.Base, found.Many
+		relation.Table = found.Table
+		if relation.Table == "" {
+			relation.Table = relation.Name
+		}
 
-	// Reconstruire la requête avec les JOINs et les nouvelles colonnes
-	if strings.Contains(strings.ToUpper(baseQuery), "SELECT") {
-		// Remplacer SELECT * par nos colonnes
-		newQuery := strings.Replace(baseQuery, "SELECT *", fmt.Sprintf("SELECT %s", strings.Join(selectColumns, ", ")), 1)
-
-		// Insérer les JOINs après FROM mais avant WHERE
-		fromIndex := strings.Index(strings.ToUpper(newQuery), " FROM ")
-		if fromIndex != -1 {
-			afterFrom := newQuery[fromIndex:]
-			insertPos := len(afterFrom)
-
-			// Trouver où insérer les JOINs (avant WHERE, ORDER BY, etc.)
-			clauses := []string{" WHERE ", " ORDER BY ", " GROUP BY ", " HAVING ", " LIMIT ", " OFFSET "}
-			for _, clause := range clauses {
-				if pos := strings.Index(strings.ToUpper(afterFrom), clause); pos != -1 && pos < insertPos {
-					insertPos = pos
-				}
-			}
+		// Une clé étrangère sans colonne cible référence la clé primaire
+		if relation.Column == "" {
+			relation.Column = "rowid"
+		}
+		if relation.Base == "" {
+			relation.Base = "rowid"
+		}
 
-			if insertPos == len(afterFrom) {
-				// Ajouter à la fin
-				newQuery = newQuery + " " + strings.Join(joinClauses, " ")
-			} else {
-				// Insérer avant la clause trouvée
-				beforeClause := afterFrom[:insertPos]
-				afterClause := afterFrom[insertPos:]
-				newQuery = newQuery[:fromIndex] + beforeClause + " " + strings.Join(joinClauses, " ") + afterClause
+		if len(relation.Columns) == 1 && relation.Columns[0] == "*" {
+			relation.Columns = columns
+			continue
+		}
+		for _, column := range relation.Columns {
+			if !containsFold(columns, column) {
+				return nil, fmt.Errorf("column %s does not exist in %s", column, relation.Name)
 			}
 		}
-
-		return newQuery, nil
 	}
 
-	return baseQuery, nil
+	return relations, nil
 }
 
-// GetForeignKeys récupère les clés étrangères d'une table
+// GetForeignKeys récupère les clés étrangères d'une table, par colonne locale
 func (e *ResourceEmbedding) GetForeignKeys(tableName string) (map[string]ForeignKeyInfo, error) {
-	// Version simplifiée qui fonctionne avec SQLite
-	// PRAGMA foreign_key_list ne supporte pas les paramètres, on construit la requête directement
-	query := fmt.Sprintf("PRAGMA foreign_key_list(%s)", tableName)
-
-	rows, err := e.db.Query(query)
+	rows, err := e.db.Query(`SELECT id, "table", "from", "to" FROM pragma_foreign_key_list(?)`, tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get foreign keys: %w", err)
 	}
@@ -157,27 +168,52 @@ func (e *ResourceEmbedding) GetForeignKeys(tableName string) (map[string]Foreign
 	foreignKeys := make(map[string]ForeignKeyInfo)
 	for rows.Next() {
 		var id int
-		var seq int
 		var table string
 		var from string
-		var to string
-		var onAction string
-		var onUpdate string
-		var match string
+		var to sql.NullString
 
-		if err := rows.Scan(&id, &seq, &table, &from, &to, &onAction, &onUpdate, &match); err != nil {
+		if err := rows.Scan(&id, &table, &from, &to); err != nil {
 			return nil, fmt.Errorf("failed to scan foreign key: %w", err)
 		}
 
 		foreignKeys[from] = ForeignKeyInfo{
 			Name:          fmt.Sprintf("fk_%d", id),
 			PrimaryTable:  table,
-			PrimaryColumn: to,
+			PrimaryColumn: to.String,
 			ForeignColumn: from,
 		}
 	}
 
-	return foreignKeys, nil
+	return foreignKeys, rows.Err()
+}
+
+// tableColumns retourne les colonnes d'une table, dans l'ordre de déclaration
+func (e *ResourceEmbedding) tableColumns(tableName string) ([]string, error) {
+	rows, err := e.db.Query("SELECT name FROM pragma_table_info(?)", tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// containsFold indique si values contient value, sans tenir compte de la casse
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
 }
 
 // ForeignKeyInfo contient les informations sur une clé étrangère
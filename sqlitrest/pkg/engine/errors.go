@@ -1,29 +1,85 @@
 package engine
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
-// ErrorType représente les types d'erreurs PostgREST
+// ErrorType est le code d'une erreur : code PGRST de PostgREST pour les erreurs
+// de requête, SQLSTATE PostgreSQL équivalent pour les erreurs de la base
+// (voir https://postgrest.org/en/stable/references/errors.html)
 type ErrorType string
 
 const (
-	ErrorTypeAuth       ErrorType = "PGRST301" // Authentication failed
-	ErrorTypePermission ErrorType = "PGRST302" // Permission denied
-	ErrorTypeNotFound   ErrorType = "PGRST303" // Resource not found
-	ErrorTypeValidation ErrorType = "PGRST304" // Validation error
-	ErrorTypeInternal   ErrorType = "PGRST500" // Internal server error
-	ErrorTypeDatabase   ErrorType = "PGRST501" // Database error
+	ErrorTypeConnection       ErrorType = "PGRST000" // base indisponible
+	ErrorTypeSchemaCache      ErrorType = "PGRST002" // schéma indisponible
+	ErrorTypeQueryString      ErrorType = "PGRST100" // paramètres de requête invalides
+	ErrorTypeInvalidMethod    ErrorType = "PGRST101" // méthode non permise pour une fonction
+	ErrorTypeInvalidBody      ErrorType = "PGRST102" // corps de requête invalide
+	ErrorTypeInvalidRange     ErrorType = "PGRST103" // plage (limit/offset, Range) invalide
+	ErrorTypeNotAcceptable    ErrorType = "PGRST107" // type de média non disponible
+	ErrorTypeSingular         ErrorType = "PGRST116" // vnd.pgrst.object avec 0 ou plusieurs lignes
+	ErrorTypeFunctionNotFound ErrorType = "PGRST202" // fonction RPC inconnue
+	ErrorTypeTableNotFound    ErrorType = "PGRST205" // table ou base inconnue
+	ErrorTypeAuth             ErrorType = "PGRST301" // JWT ou clé API invalide
+	ErrorTypeInternal         ErrorType = "PGRSTX00" // erreur interne
+
+	ErrorTypeUniqueViolation     ErrorType = "23505"
+	ErrorTypeForeignKeyViolation ErrorType = "23503"
+	ErrorTypeNotNullViolation    ErrorType = "23502"
+	ErrorTypeCheckViolation      ErrorType = "23514"
+	ErrorTypeRaiseException      ErrorType = "P0001" // RAISE(ABORT, ...) dans un trigger
+	ErrorTypePermission          ErrorType = "42501" // droit (grant) manquant
+	ErrorTypeUndefinedColumn     ErrorType = "42703"
+	ErrorTypeUndefinedFunction   ErrorType = "42883"
+	ErrorTypeReadOnly            ErrorType = "25006" // écriture sur une base en lecture seule
+	ErrorTypeBusy                ErrorType = "55P03" // base verrouillée (SQLITE_BUSY)
+	ErrorTypeTimeout             ErrorType = "57014" // requête interrompue après le délai
+	ErrorTypeTooManyRequests     ErrorType = "53400" // limite de débit atteinte
 )
 
+// errorStatus associe chaque code à son statut HTTP
+var errorStatus = map[ErrorType]int{
+	ErrorTypeConnection:       http.StatusServiceUnavailable,
+	ErrorTypeSchemaCache:      http.StatusServiceUnavailable,
+	ErrorTypeQueryString:      http.StatusBadRequest,
+	ErrorTypeInvalidMethod:    http.StatusMethodNotAllowed,
+	ErrorTypeInvalidBody:      http.StatusBadRequest,
+	ErrorTypeInvalidRange:     http.StatusRequestedRangeNotSatisfiable,
+	ErrorTypeNotAcceptable:    http.StatusNotAcceptable,
+	ErrorTypeSingular:         http.StatusNotAcceptable,
+	ErrorTypeFunctionNotFound: http.StatusNotFound,
+	ErrorTypeTableNotFound:    http.StatusNotFound,
+	ErrorTypeAuth:             http.StatusUnauthorized,
+	ErrorTypeInternal:         http.StatusInternalServerError,
+
+	ErrorTypeUniqueViolation:     http.StatusConflict,
+	ErrorTypeForeignKeyViolation: http.StatusConflict,
+	ErrorTypeNotNullViolation:    http.StatusBadRequest,
+	ErrorTypeCheckViolation:      http.StatusBadRequest,
+	ErrorTypeRaiseException:      http.StatusBadRequest,
+	ErrorTypePermission:          http.StatusForbidden,
+	ErrorTypeUndefinedColumn:     http.StatusBadRequest,
+	ErrorTypeUndefinedFunction:   http.StatusNotFound,
+	ErrorTypeReadOnly:            http.StatusMethodNotAllowed,
+	ErrorTypeBusy:                http.StatusServiceUnavailable,
+	ErrorTypeTimeout:             http.StatusGatewayTimeout,
+	ErrorTypeTooManyRequests:     http.StatusTooManyRequests,
+}
+
 // APIError représente une erreur API compatible PostgREST
 type APIError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
-	Hint    string `json:"hint,omitempty"`
+	Details string `json:"details"`
+	Hint    string `json:"hint"`
 	Status  int    `json:"-"`
+
+	Violations []Violation `json:"violations,omitempty"` // valeurs refusées d'un corps de requête
 }
 
 // Error implémente l'interface error
@@ -31,29 +87,34 @@ func (e *APIError) Error() string {
 	return e.Message
 }
 
+// MarshalJSON écrit details et hint à null quand ils sont vides, comme PostgREST
+func (e *APIError) MarshalJSON() ([]byte, error) {
+	nullable := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	return json.Marshal(struct {
+		Code       string      `json:"code"`
+		Message    string      `json:"message"`
+		Details    *string     `json:"details"`
+		Hint       *string     `json:"hint"`
+		Violations []Violation `json:"violations,omitempty"`
+	}{e.Code, e.Message, nullable(e.Details), nullable(e.Hint), e.Violations})
+}
+
 // NewAPIError crée une nouvelle erreur API
 func NewAPIError(errorType ErrorType, message string, details ...string) *APIError {
-	errorMap := map[ErrorType]struct {
-		code   string
-		status int
-	}{
-		ErrorTypeAuth:       {"PGRST301", http.StatusUnauthorized},
-		ErrorTypePermission: {"PGRST302", http.StatusForbidden},
-		ErrorTypeNotFound:   {"PGRST303", http.StatusNotFound},
-		ErrorTypeValidation: {"PGRST304", http.StatusBadRequest},
-		ErrorTypeInternal:   {"PGRST500", http.StatusInternalServerError},
-		ErrorTypeDatabase:   {"PGRST501", http.StatusInternalServerError},
-	}
-
-	info, exists := errorMap[errorType]
+	status, exists := errorStatus[errorType]
 	if !exists {
-		info = errorMap[ErrorTypeInternal]
+		status = http.StatusInternalServerError
 	}
 
 	err := &APIError{
-		Code:    info.code,
+		Code:    string(errorType),
 		Message: message,
-		Status:  info.status,
+		Status:  status,
 	}
 
 	if len(details) > 0 {
@@ -63,15 +124,40 @@ func NewAPIError(errorType ErrorType, message string, details ...string) *APIErr
 	return err
 }
 
-// Erreurs prédéfinies
-var (
-	ErrAuthenticationFailed = NewAPIError(ErrorTypeAuth, "Authentication failed", "Invalid or missing JWT token")
-	ErrPermissionDenied     = NewAPIError(ErrorTypePermission, "Permission denied", "You don't have permission to access this resource")
-	ErrResourceNotFound     = NewAPIError(ErrorTypeNotFound, "Resource not found", "The requested resource does not exist")
-	ErrInvalidRequest       = NewAPIError(ErrorTypeValidation, "Invalid request", "The request parameters are invalid")
-	ErrDatabaseError        = NewAPIError(ErrorTypeDatabase, "Database error", "An error occurred while executing the database query")
-	ErrInternalError        = NewAPIError(ErrorTypeInternal, "Internal server error", "An unexpected error occurred")
-)
+// WithHint ajoute une indication à l'erreur
+func (e *APIError) WithHint(hint string) *APIError {
+	e.Hint = hint
+	return e
+}
+
+// WriteError écrit une erreur au format PostgREST (code, message, details, hint).
+// Les erreurs qui ne sont pas des APIError sont converties par HandleError.
+func WriteError(w http.ResponseWriter, err error) {
+	apiErr := NewErrorHandler().HandleError(err)
+
+	if ErrorType(apiErr.Code) == ErrorTypeBusy && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", "1")
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(apiErr)
+}
+
+// ErrorTrailers sont les trailers déclarés par une réponse en streaming pour
+// signaler une erreur survenue après l'envoi du statut
+const ErrorTrailers = "X-Error-Code, X-Error-Message, X-Error-Details"
+
+// WriteErrorTrailers transmet une erreur dans les trailers d'une réponse en
+// streaming (déclarés avec ErrorTrailers avant WriteHeader) ; le corps, déjà
+// commencé, reste interrompu
+func WriteErrorTrailers(w http.ResponseWriter, err error) {
+	apiErr := NewErrorHandler().HandleError(err)
+	w.Header().Set("X-Error-Code", apiErr.Code)
+	w.Header().Set("X-Error-Message", apiErr.Message)
+	if apiErr.Details != "" {
+		w.Header().Set("X-Error-Details", apiErr.Details)
+	}
+}
 
 // ErrorHandler gère les erreurs de manière cohérente
 type ErrorHandler struct{}
@@ -81,56 +167,112 @@ func NewErrorHandler() *ErrorHandler {
 	return &ErrorHandler{}
 }
 
-// HandleError convertit une erreur en APIError
+// Codes de résultat SQLite (https://sqlite.org/rescode.html)
+const (
+	sqliteError      = 1
+	sqliteBusy       = 5
+	sqliteLocked     = 6
+	sqliteReadOnly   = 8
+	sqliteConstraint = 19
+
+	sqliteConstraintCheck      = 275
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintNotNull    = 1299
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintTrigger    = 1811
+	sqliteConstraintUnique     = 2067
+	sqliteConstraintRowID      = 2579
+)
+
+// sqliteCoder est implémenté par les erreurs du pilote SQLite
+type sqliteCoder interface {
+	error
+	Code() int
+}
+
+// HandleError convertit une erreur en APIError. Les erreurs SQLite sont classées
+// par code de résultat ; les autres deviennent des erreurs internes.
 func (h *ErrorHandler) HandleError(err error) *APIError {
 	if err == nil {
 		return nil
 	}
 
 	// Si c'est déjà une APIError, la retourner directement
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr
 	}
 
-	// Analyser le message d'erreur pour déterminer le type
-	errMsg := err.Error()
-
-	// Erreurs d'authentification
-	if contains(errMsg, []string{"authentication", "unauthorized", "invalid token", "jwt"}) {
-		return NewAPIError(ErrorTypeAuth, "Authentication failed", errMsg)
+	var sqliteErr sqliteCoder
+	if errors.As(err, &sqliteErr) {
+		return sqliteAPIError(sqliteErr)
 	}
 
-	// Erreurs de permission
-	if contains(errMsg, []string{"permission", "forbidden", "access denied", "policy"}) {
-		return NewAPIError(ErrorTypePermission, "Permission denied", errMsg)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewAPIError(ErrorTypeTimeout, "Canceling statement due to statement timeout", err.Error())
 	}
 
-	// Erreurs de validation
-	if contains(errMsg, []string{"invalid", "bad request", "validation", "parse"}) {
-		return NewAPIError(ErrorTypeValidation, "Invalid request", errMsg)
+	return NewAPIError(ErrorTypeInternal, "Internal server error", err.Error())
+}
+
+// sqliteAPIError associe un code de résultat SQLite à une erreur PostgREST
+func sqliteAPIError(err sqliteCoder) *APIError {
+	message := sqliteMessage(err)
+
+	switch err.Code() {
+	case sqliteConstraintUnique, sqliteConstraintPrimaryKey, sqliteConstraintRowID:
+		return NewAPIError(ErrorTypeUniqueViolation, message)
+	case sqliteConstraintForeignKey:
+		return NewAPIError(ErrorTypeForeignKeyViolation, message)
+	case sqliteConstraintNotNull:
+		return NewAPIError(ErrorTypeNotNullViolation, message)
+	case sqliteConstraintCheck:
+		return NewAPIError(ErrorTypeCheckViolation, message)
+	case sqliteConstraintTrigger:
+		return NewAPIError(ErrorTypeRaiseException, message)
 	}
 
-	// Erreurs de base de données
-	if contains(errMsg, []string{"database", "sql", "query", "execution failed"}) {
-		return NewAPIError(ErrorTypeDatabase, "Database error", errMsg)
+	switch err.Code() & 0xff {
+	case sqliteBusy, sqliteLocked:
+		return NewAPIError(ErrorTypeBusy, "Database is busy", message).WithHint("Retry the request")
+	case sqliteReadOnly:
+		return NewAPIError(ErrorTypeReadOnly, "Database is read-only", message)
+	case sqliteConstraint:
+		return NewAPIError(ErrorTypeCheckViolation, message)
+	case sqliteError:
+		switch {
+		case strings.HasPrefix(message, "no such table"):
+			return NewAPIError(ErrorTypeTableNotFound, message)
+		case strings.HasPrefix(message, "no such column"), strings.Contains(message, "has no column named"):
+			return NewAPIError(ErrorTypeUndefinedColumn, message)
+		case strings.HasPrefix(message, "no such function"):
+			return NewAPIError(ErrorTypeUndefinedFunction, message)
+		}
 	}
 
-	// Erreur interne par défaut
-	return NewAPIError(ErrorTypeInternal, "Internal server error", errMsg)
+	return NewAPIError(ErrorTypeInternal, "Database error", message)
 }
 
-// contains vérifie si le message contient une des chaînes
-func contains(message string, substrings []string) bool {
-	for _, substr := range substrings {
-		if len(message) >= len(substr) {
-			for i := 0; i <= len(message)-len(substr); i++ {
-				if message[i:i+len(substr)] == substr {
-					return true
-				}
-			}
+// sqliteMessage retourne le message SQLite sans le libellé ni les codes ajoutés
+// par le pilote : "constraint failed: UNIQUE constraint failed: users.email (2067)"
+// devient "UNIQUE constraint failed: users.email"
+func sqliteMessage(err sqliteCoder) string {
+	message := err.Error()
+	for strings.HasSuffix(message, ")") {
+		i := strings.LastIndex(message, " (")
+		if i == -1 {
+			break
+		}
+		code := message[i+2 : len(message)-1]
+		if strings.Trim(code, "0123456789") != "" && !strings.HasPrefix(code, "SQLITE_") {
+			break
 		}
+		message = message[:i]
+	}
+	if _, detail, found := strings.Cut(message, ": "); found {
+		message = detail
 	}
-	return false
+	return message
 }
 
 // WrapError enveloppe une erreur avec contexte
@@ -139,24 +281,51 @@ func (h *ErrorHandler) WrapError(err error, context string) *APIError {
 		return nil
 	}
 
-	apiErr := h.HandleError(err)
+	apiErr := *h.HandleError(err)
 	if apiErr.Details != "" {
 		apiErr.Details = fmt.Sprintf("%s: %s", context, apiErr.Details)
 	} else {
 		apiErr.Details = context
 	}
 
-	return apiErr
+	return &apiErr
+}
+
+// QueryStringError crée une erreur sur les paramètres de la requête
+func (h *ErrorHandler) QueryStringError(err error) *APIError {
+	return NewAPIError(ErrorTypeQueryString, "Invalid query parameters", err.Error())
+}
+
+// BodyError crée une erreur sur un corps JSON illisible
+func (h *ErrorHandler) BodyError(err error) *APIError {
+	return NewAPIError(ErrorTypeInvalidBody, "Invalid JSON in request body", err.Error())
 }
 
 // ValidationError crée une erreur de validation
 func (h *ErrorHandler) ValidationError(field, message string) *APIError {
-	return NewAPIError(ErrorTypeValidation, fmt.Sprintf("Validation error on field %s", field), message)
+	return NewAPIError(ErrorTypeInvalidBody, fmt.Sprintf("Validation error on field %s", field), message)
 }
 
-// DatabaseError crée une erreur de base de données
-func (h *ErrorHandler) DatabaseError(query string, err error) *APIError {
-	return h.WrapError(err, fmt.Sprintf("Database query failed: %s", query))
+// ViolationsError crée une erreur de validation listant toutes les valeurs refusées
+func (h *ErrorHandler) ViolationsError(table string, violations []Violation) *APIError {
+	details := make([]string, len(violations))
+	for i, violation := range violations {
+		details[i] = fmt.Sprintf("%s: %s", violation.Column, violation.Message)
+	}
+
+	err := NewAPIError(ErrorTypeInvalidBody, fmt.Sprintf("Invalid request body for %s", table), strings.Join(details, "; "))
+	err.Violations = violations
+	return err
+}
+
+// DatabaseError crée une erreur de base de données ; la requête SQL n'est pas
+// exposée au client
+func (h *ErrorHandler) DatabaseError(operation string, err error) *APIError {
+	apiErr := h.HandleError(err)
+	if apiErr.Code == string(ErrorTypeInternal) {
+		return NewAPIError(ErrorTypeInternal, fmt.Sprintf("%s failed", operation), apiErr.Details)
+	}
+	return apiErr
 }
 
 // AuthError crée une erreur d'authentification
@@ -165,6 +334,17 @@ func (h *ErrorHandler) AuthError(reason string) *APIError {
 }
 
 // PermissionError crée une erreur de permission
-func (h *ErrorHandler) PermissionError(resource string) *APIError {
-	return NewAPIError(ErrorTypePermission, "Permission denied", fmt.Sprintf("No permission to access %s", resource))
+func (h *ErrorHandler) PermissionError(privilege, resource, role string) *APIError {
+	return NewAPIError(ErrorTypePermission, fmt.Sprintf("Permission denied for %s", resource),
+		fmt.Sprintf("Role %s is missing %s on %s", role, privilege, resource))
+}
+
+// NotFoundError crée une erreur de table ou de base inconnue
+func (h *ErrorHandler) NotFoundError(resource string) *APIError {
+	return NewAPIError(ErrorTypeTableNotFound, fmt.Sprintf("Could not find %s", resource))
+}
+
+// ReadOnlyError crée l'erreur d'une écriture sur une base en lecture seule
+func (h *ErrorHandler) ReadOnlyError(dbName string) *APIError {
+	return NewAPIError(ErrorTypeReadOnly, fmt.Sprintf("Database %s is read-only", dbName))
 }
@@ -40,6 +40,9 @@ type QueryParameters struct {
 	Order      []OrderClause
 	Limit      *int
 	Offset     *int
+	Cursor     string      // curseur opaque reçu (cursor=...)
+	UseCursor  bool        // paramètre cursor présent, vide pour la première page
+	After      []CursorKey // position décodée du curseur : reprise après cette ligne
 	Table      string
 	Conditions []LogicalCondition
 }
@@ -123,6 +126,9 @@ func (p *QueryParser) ParseQuery(path string, queryParams url.Values) (*QueryPar
 				return nil, fmt.Errorf("invalid offset: %s", value)
 			}
 			params.Offset = &offset
+		} else if key == "cursor" {
+			params.Cursor = value
+			params.UseCursor = true
 		} else if key == "or" || key == "and" {
 			condition, err := p.parseLogicalCondition(value)
 			if err != nil {
@@ -154,7 +160,7 @@ func (p *QueryParser) extractTableFromPath(path string) string {
 
 // isFilterOperator vérifie si la clé est un opérateur de filtre
 func (p *QueryParser) isFilterOperator(key string) bool {
-	return strings.Contains(key, ".") && !strings.HasPrefix(key, "order.") && !strings.HasPrefix(key, "select.") && key != "limit" && key != "offset" && key != "and" && key != "or"
+	return strings.Contains(key, ".") && !strings.HasPrefix(key, "order.") && !strings.HasPrefix(key, "select.") && key != "limit" && key != "offset" && key != "cursor" && key != "and" && key != "or"
 }
 
 // parseFilter parse un filtre individuel
@@ -191,11 +197,23 @@ func (p *QueryParser) parseSelect(value string) []string {
 		return []string{"*"}
 	}
 
-	columns := strings.Split(value, ",")
-	for i, col := range columns {
-		columns[i] = strings.TrimSpace(col)
+	// Les virgules entre parenthèses séparent les colonnes d'une relation incluse
+	var columns []string
+	depth, start := 0, 0
+	for i, char := range value {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				columns = append(columns, strings.TrimSpace(value[start:i]))
+				start = i + 1
+			}
+		}
 	}
-	return columns
+	return append(columns, strings.TrimSpace(value[start:]))
 }
 
 // parseOrder parse les clauses de tri
@@ -234,7 +252,7 @@ func (p *QueryParser) parseOrder(value string) ([]OrderClause, error) {
 // isPostgRESTFilter vérifie si la clé est au format PostgREST (column=operator.value)
 func (p *QueryParser) isPostgRESTFilter(key string) bool {
 	// Vérifier si la valeur contient un opérateur PostgREST
-	return !strings.Contains(key, ".") && key != "select" && key != "order" && key != "limit" && key != "offset" && key != "and" && key != "or"
+	return !strings.Contains(key, ".") && key != "select" && key != "order" && key != "limit" && key != "offset" && key != "cursor" && key != "and" && key != "or"
 }
 
 // parsePostgRESTFilter parse un filtre au format PostgREST (column=operator.value)
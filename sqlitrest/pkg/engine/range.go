@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ApplyRange applique l'en-tête Range (PostgREST : "Range: 0-24", "Range: 10-",
+// Range-Unit: items) aux paramètres. Combiné aux paramètres limit/offset de l'URL,
+// la plage retenue est l'intersection des deux, comme PostgREST. Un en-tête Range
+// d'une autre unité est ignoré.
+func (p *QueryParser) ApplyRange(params *QueryParameters, rangeHeader, rangeUnit string) error {
+	rangeHeader = strings.TrimSpace(rangeHeader)
+	if rangeHeader == "" {
+		return nil
+	}
+	if rangeUnit != "" && !strings.EqualFold(strings.TrimSpace(rangeUnit), "items") {
+		return nil
+	}
+	rangeHeader = strings.TrimPrefix(rangeHeader, "items=")
+
+	bounds := strings.SplitN(rangeHeader, "-", 2)
+	if len(bounds) != 2 {
+		return fmt.Errorf("invalid Range header: %s", rangeHeader)
+	}
+
+	lower, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil || lower < 0 {
+		return fmt.Errorf("invalid Range header: %s", rangeHeader)
+	}
+
+	upper := -1 // plage ouverte
+	if upperText := strings.TrimSpace(bounds[1]); upperText != "" {
+		if upper, err = strconv.Atoi(upperText); err != nil {
+			return fmt.Errorf("invalid Range header: %s", rangeHeader)
+		}
+		if upper < lower {
+			return fmt.Errorf("invalid Range header: the lower bound %d is greater than the upper bound %d", lower, upper)
+		}
+	}
+
+	// Intersection avec la plage [offset, offset+limit-1] de l'URL
+	if params.Offset != nil && *params.Offset > lower {
+		lower = *params.Offset
+	}
+	if params.Limit != nil {
+		urlUpper := *params.Limit - 1
+		if params.Offset != nil {
+			urlUpper += *params.Offset
+		}
+		if upper < 0 || urlUpper < upper {
+			upper = urlUpper
+		}
+	}
+
+	var limit *int
+	if upper >= 0 || params.Limit != nil {
+		count := upper - lower + 1
+		if count < 0 {
+			count = 0
+		}
+		limit = &count
+	}
+
+	params.Offset = &lower
+	params.Limit = limit
+	return nil
+}
+
+// ContentRange formate l'en-tête Content-Range d'une réponse paginée :
+// "0-24/*" sans comptage, "0-24/3573" avec un total, "*/0" sans ligne
+func ContentRange(offset, rows int, total *int64) string {
+	totalText := "*"
+	if total != nil {
+		totalText = strconv.FormatInt(*total, 10)
+	}
+	if rows == 0 {
+		return "*/" + totalText
+	}
+	return fmt.Sprintf("%d-%d/%s", offset, offset+rows-1, totalText)
+}
+
+// RangeStatus retourne le statut d'une réponse paginée : 206 Partial Content si
+// le total est connu et que la réponse n'en contient qu'une partie, 416 si la
+// plage commence après la dernière ligne, 200 sinon
+func RangeStatus(offset, rows int, total *int64) int {
+	switch {
+	case total == nil:
+		return http.StatusOK
+	case int64(offset) > *total:
+		return http.StatusRequestedRangeNotSatisfiable
+	case int64(rows) < *total:
+		return http.StatusPartialContent
+	}
+	return http.StatusOK
+}
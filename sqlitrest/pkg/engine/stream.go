@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultStreamThreshold est le seuil de streaming par défaut
+const DefaultStreamThreshold = 1000
+
+// StreamRows retourne le nombre de lignes au-delà duquel une lecture sans limite
+// est envoyée en streaming plutôt que matérialisée
+func (c PaginationConfig) StreamRows() int {
+	if c.StreamThreshold > 0 {
+		return c.StreamThreshold
+	}
+	return DefaultStreamThreshold
+}
+
+// RowReader parcourt les lignes d'un SELECT une à une, sans les matérialiser :
+// les réponses volumineuses sont encodées au fil de la lecture
+type RowReader struct {
+	rows    *sql.Rows
+	columns []string
+	values  []interface{}
+	ptrs    []interface{}
+	json    []bool // colonnes JSON, voir Executor.SetJSONColumns
+	err     error
+}
+
+// QuerySelect exécute un SELECT et retourne un lecteur de lignes ; l'appelant
+// doit le fermer
+func (e *Executor) QuerySelect(query string, args []interface{}) (*RowReader, error) {
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	reader := &RowReader{
+		rows:    rows,
+		columns: columns,
+		values:  make([]interface{}, len(columns)),
+		ptrs:    make([]interface{}, len(columns)),
+		json:    make([]bool, len(columns)),
+	}
+	for i, column := range columns {
+		reader.ptrs[i] = &reader.values[i]
+		reader.json[i] = containsFold(e.jsonColumns, column)
+	}
+	return reader, nil
+}
+
+// Columns retourne les colonnes du SELECT, dans l'ordre
+func (r *RowReader) Columns() []string {
+	return r.columns
+}
+
+// Next lit la ligne suivante ; false à la fin des lignes ou en cas d'erreur (voir Err)
+func (r *RowReader) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+	if err := r.rows.Scan(r.ptrs...); err != nil {
+		r.err = fmt.Errorf("failed to scan row: %w", err)
+		return false
+	}
+	for i, value := range r.values {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		if text, ok := value.(string); ok && r.json[i] {
+			value = json.RawMessage(text)
+		}
+		r.values[i] = value
+	}
+	return true
+}
+
+// Values retourne les valeurs de la ligne courante dans l'ordre des colonnes ;
+// le tableau est réutilisé par l'appel suivant à Next
+func (r *RowReader) Values() []interface{} {
+	return r.values
+}
+
+// Row retourne la ligne courante sous forme de map colonne -> valeur
+func (r *RowReader) Row() map[string]interface{} {
+	row := make(map[string]interface{}, len(r.columns))
+	for i, column := range r.columns {
+		row[column] = r.values[i]
+	}
+	return row
+}
+
+// Err retourne l'erreur survenue pendant le parcours
+func (r *RowReader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %w", err)
+	}
+	return nil
+}
+
+// Close libère la connexion du lecteur
+func (r *RowReader) Close() error {
+	return r.rows.Close()
+}
+
+// RowEncoder encode des lignes en objets JSON dont les clés suivent l'ordre des
+// colonnes du SELECT (json.Marshal d'une map les trierait)
+type RowEncoder struct {
+	keys [][]byte
+}
+
+// NewRowEncoder prépare l'encodage des clés des colonnes
+func NewRowEncoder(columns []string) *RowEncoder {
+	keys := make([][]byte, len(columns))
+	for i, column := range columns {
+		key, _ := json.Marshal(column)
+		keys[i] = append(key, ':')
+	}
+	return &RowEncoder{keys: keys}
+}
+
+// AppendJSON ajoute à buf l'objet JSON d'une ligne
+func (e *RowEncoder) AppendJSON(buf []byte, values []interface{}) ([]byte, error) {
+	buf = append(buf, '{')
+	for i, value := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return buf, fmt.Errorf("failed to encode column %s: %w", e.keys[i][:len(e.keys[i])-1], err)
+		}
+		buf = append(append(buf, e.keys[i]...), encoded...)
+	}
+	return append(buf, '}'), nil
+}
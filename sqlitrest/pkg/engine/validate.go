@@ -0,0 +1,318 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation décrit une valeur refusée par les contraintes d'une colonne
+type Violation struct {
+	Column  string `json:"column"`
+	Message string `json:"message"`
+}
+
+// Column retourne la colonne de ce nom (insensible à la casse)
+func (s *SchemaInfo) Column(name string) (*ColumnInfo, bool) {
+	for i := range s.Columns {
+		if strings.EqualFold(s.Columns[i].Name, name) {
+			return &s.Columns[i], true
+		}
+	}
+	return nil, false
+}
+
+// ValidateInsert vérifie le corps d'une insertion : colonnes inconnues, colonnes
+// requises absentes, NULL dans une colonne NOT NULL, types JSON incompatibles avec
+// l'affinité de la colonne et contraintes extraites du DDL. Toutes les violations
+// sont retournées, triées par colonne.
+func (s *SchemaInfo) ValidateInsert(row map[string]interface{}) []Violation {
+	violations := s.validate(row, true)
+	for _, column := range s.Columns {
+		if !column.RequiredOnInsert() {
+			continue
+		}
+		if _, present := lookup(row, column.Name); !present {
+			violations = append(violations, Violation{Column: column.Name, Message: "is required (NOT NULL without default)"})
+		}
+	}
+	sortViolations(violations)
+	return violations
+}
+
+// ValidateUpdate vérifie le corps d'une mise à jour ; seules les colonnes
+// présentes sont contrôlées
+func (s *SchemaInfo) ValidateUpdate(row map[string]interface{}) []Violation {
+	violations := s.validate(row, false)
+	sortViolations(violations)
+	return violations
+}
+
+// validate contrôle chaque valeur de la ligne
+func (s *SchemaInfo) validate(row map[string]interface{}, insert bool) []Violation {
+	var violations []Violation
+	for name, value := range row {
+		column, exists := s.Column(name)
+		if !exists {
+			violations = append(violations, Violation{Column: name, Message: fmt.Sprintf("column does not exist in %s", s.TableName)})
+			continue
+		}
+
+		var message string
+		switch {
+		case column.Generated:
+			message = "generated column cannot be written"
+		case value == nil:
+			// NULL sur la clé INTEGER PRIMARY KEY à l'insertion attribue un rowid
+			if column.NotNull && !(insert && column.isRowID()) {
+				message = "cannot be null"
+			}
+		default:
+			if message = column.checkType(value); message == "" {
+				message = column.checkValue(value)
+			}
+		}
+		if message != "" {
+			violations = append(violations, Violation{Column: column.Name, Message: message})
+		}
+	}
+	return violations
+}
+
+// RequiredOnInsert indique si la colonne doit figurer dans une insertion :
+// NOT NULL sans défaut, hors clé INTEGER PRIMARY KEY et colonnes générées
+func (c *ColumnInfo) RequiredOnInsert() bool {
+	return c.NotNull && c.DefaultValue == "" && !c.isRowID() && !c.Generated
+}
+
+// isRowID indique si la colonne est un alias du rowid (INTEGER PRIMARY KEY)
+func (c *ColumnInfo) isRowID() bool {
+	return c.IsPrimaryKey && strings.EqualFold(c.Type, "INTEGER")
+}
+
+// IsJSON indique si la colonne stocke du JSON (type déclaré JSON ou JSONB)
+func (c *ColumnInfo) IsJSON() bool {
+	return strings.Contains(strings.ToUpper(c.Type), "JSON")
+}
+
+// Affinity retourne l'affinité SQLite d'un type déclaré (INTEGER, TEXT, BLOB,
+// REAL ou NUMERIC), selon les règles de https://sqlite.org/datatype3.html
+func Affinity(declaredType string) string {
+	t := strings.ToUpper(declaredType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case t == "", strings.Contains(t, "BLOB"):
+		return "BLOB"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "REAL"
+	}
+	return "NUMERIC"
+}
+
+// checkType vérifie qu'une valeur JSON non nulle convient à la colonne. Les objets
+// et tableaux ne sont acceptés que par les colonnes JSON ; les booléens sont
+// stockés comme 0/1 dans les colonnes INTEGER et NUMERIC.
+func (c *ColumnInfo) checkType(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		if c.IsJSON() {
+			return ""
+		}
+		return fmt.Sprintf("nested %s not allowed in a column of type %s", jsonTypeName(value), c.displayType())
+	case string:
+		switch Affinity(c.Type) {
+		case "INTEGER", "REAL":
+			if !c.IsJSON() {
+				return fmt.Sprintf("expected %s, got string", affinityJSONType(c.Type))
+			}
+		}
+	case float64:
+		switch Affinity(c.Type) {
+		case "TEXT":
+			if !c.IsJSON() {
+				return "expected string, got number"
+			}
+		case "INTEGER":
+			if v != math.Trunc(v) {
+				return "expected integer, got number"
+			}
+		}
+	case bool:
+		switch Affinity(c.Type) {
+		case "TEXT", "REAL":
+			if !c.IsJSON() {
+				return fmt.Sprintf("expected %s, got boolean", affinityJSONType(c.Type))
+			}
+		}
+	}
+	return ""
+}
+
+// affinityJSONType retourne le type JSON attendu pour une affinité
+func affinityJSONType(declaredType string) string {
+	switch Affinity(declaredType) {
+	case "INTEGER":
+		return "integer"
+	case "REAL":
+		return "number"
+	}
+	return "string"
+}
+
+// displayType retourne le type déclaré de la colonne pour les messages
+func (c *ColumnInfo) displayType() string {
+	if c.Type == "" {
+		return "BLOB"
+	}
+	return c.Type
+}
+
+// jsonTypeName retourne le nom JSON d'une valeur décodée
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// EncodeJSONValues sérialise les objets et tableaux destinés aux colonnes JSON
+// pour qu'ils soient stockés sous forme de texte JSON
+func (s *SchemaInfo) EncodeJSONValues(row map[string]interface{}) error {
+	for name, value := range row {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			continue
+		}
+		if column, exists := s.Column(name); !exists || !column.IsJSON() {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		row[name] = string(encoded)
+	}
+	return nil
+}
+
+// lookup retourne la valeur d'une colonne dans une ligne (insensible à la casse)
+func lookup(row map[string]interface{}, column string) (interface{}, bool) {
+	if value, exists := row[column]; exists {
+		return value, true
+	}
+	for name, value := range row {
+		if strings.EqualFold(name, column) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// sortViolations trie les violations par colonne pour un message stable
+func sortViolations(violations []Violation) {
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Column < violations[j].Column })
+}
+
+// checkValue vérifie une valeur non nulle contre les contraintes extraites du DDL
+// (énumérations, bornes, longueurs) ; les CHECK non reconnus restent vérifiés par SQLite
+func (c *ColumnInfo) checkValue(value interface{}) string {
+	if len(c.Enum) > 0 && !enumContains(c.Enum, value, c.Collation) {
+		allowed := make([]string, len(c.Enum))
+		for i, v := range c.Enum {
+			allowed[i] = fmt.Sprintf("%v", v)
+		}
+		return fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", "))
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if c.Minimum != nil && v < *c.Minimum {
+			return fmt.Sprintf("must be >= %v", *c.Minimum)
+		}
+		if c.ExclusiveMinimum != nil && v <= *c.ExclusiveMinimum {
+			return fmt.Sprintf("must be > %v", *c.ExclusiveMinimum)
+		}
+		if c.Maximum != nil && v > *c.Maximum {
+			return fmt.Sprintf("must be <= %v", *c.Maximum)
+		}
+		if c.ExclusiveMaximum != nil && v >= *c.ExclusiveMaximum {
+			return fmt.Sprintf("must be < %v", *c.ExclusiveMaximum)
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if c.MinLength != nil && length < *c.MinLength {
+			return fmt.Sprintf("length must be >= %d", *c.MinLength)
+		}
+		if c.MaxLength != nil && length > *c.MaxLength {
+			return fmt.Sprintf("length must be <= %d", *c.MaxLength)
+		}
+	}
+
+	return ""
+}
+
+// enumContains compare une valeur JSON aux littéraux d'un IN (...). Les chaînes
+// sont comparées selon la collation de la colonne, comme le fait SQLite ; une
+// collation inconnue laisse la vérification à SQLite.
+func enumContains(enum []interface{}, value interface{}, collation string) bool {
+	for _, allowed := range enum {
+		switch a := allowed.(type) {
+		case int64:
+			if v, ok := value.(float64); ok && v == float64(a) {
+				return true
+			}
+		case float64:
+			if v, ok := value.(float64); ok && v == a {
+				return true
+			}
+		case string:
+			if v, ok := value.(string); ok {
+				equal, known := collationEqual(collation, v, a)
+				if equal || !known {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// collationEqual compare deux chaînes selon une collation intégrée de SQLite :
+// BINARY, NOCASE (casse ASCII ignorée) ou RTRIM (espaces finaux ignorés)
+func collationEqual(collation, a, b string) (equal, known bool) {
+	switch strings.ToUpper(collation) {
+	case "", "BINARY":
+		return a == b, true
+	case "NOCASE":
+		return asciiFold(a) == asciiFold(b), true
+	case "RTRIM":
+		return strings.TrimRight(a, " ") == strings.TrimRight(b, " "), true
+	}
+	return false, false
+}
+
+// asciiFold met en minuscules les seules lettres ASCII, comme NOCASE
+func asciiFold(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,73 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+)
+
+// tableAccess liste les opérations qu'un rôle peut effectuer sur une table
+type tableAccess struct {
+	read   bool
+	insert bool
+	update bool
+	delete bool
+}
+
+// any indique si au moins une opération est permise
+func (a tableAccess) any() bool {
+	return a.read || a.insert || a.update || a.delete
+}
+
+// tableAccessFor calcule les opérations permises sur une table par les droits
+// et les politiques. Les politiques INSERT ne sont pas appliquées aux écritures
+// et ne sont donc pas consultées.
+func (g *OpenAPIGenerator) tableAccessFor(authCtx *auth.AuthContext, table string, writable bool) tableAccess {
+	access := tableAccess{
+		read: g.allowed(authCtx, auth.PrivilegeSelect, table) && g.permits(authCtx, table, "SELECT"),
+	}
+	if writable {
+		access.insert = g.allowed(authCtx, auth.PrivilegeInsert, table)
+		access.update = g.allowed(authCtx, auth.PrivilegeUpdate, table) && g.permits(authCtx, table, "UPDATE")
+		access.delete = g.allowed(authCtx, auth.PrivilegeDelete, table) && g.permits(authCtx, table, "DELETE")
+	}
+	return access
+}
+
+// allowed vérifie un droit ; sans gestionnaire de droits tout est permis
+func (g *OpenAPIGenerator) allowed(authCtx *auth.AuthContext, privilege auth.Privilege, object string) bool {
+	return g.grants == nil || g.grants.Check(authCtx, privilege, object)
+}
+
+// permits vérifie que les politiques laissent un accès possible
+func (g *OpenAPIGenerator) permits(authCtx *auth.AuthContext, table, action string) bool {
+	return g.policies == nil || g.policies.Permits(table, action, authCtx)
+}
+
+// roleKey identifie les contextes qui produisent le même document quand les
+// politiques ne dépendent pas de l'identité : rôle et permissions du token
+func roleKey(authCtx *auth.AuthContext) string {
+	permissions := append([]string(nil), authCtx.Permissions...)
+	sort.Strings(permissions)
+	return fmt.Sprintf("%s|%t|%s", authCtx.Role, authCtx.Authenticated, strings.Join(permissions, ","))
+}
+
+// stateVersion combine les versions du schéma, des politiques et des fonctions RPC ;
+// les documents en cache sont abandonnés dès qu'elle change
+func (g *OpenAPIGenerator) stateVersion() (string, error) {
+	schemaVersion, err := g.cache.SchemaVersion()
+	if err != nil {
+		return "", err
+	}
+
+	var policiesVersion, rpcVersion uint64
+	if g.policies != nil {
+		policiesVersion = g.policies.Version()
+	}
+	if g.rpc != nil {
+		rpcVersion = g.rpc.Version()
+	}
+	return fmt.Sprintf("%d/%d/%d", schemaVersion, policiesVersion, rpcVersion), nil
+}
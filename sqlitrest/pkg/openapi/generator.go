@@ -1,19 +1,27 @@
 package openapi
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/cl-ment/sqlitrest/pkg/engine"
+	"github.com/cl-ment/sqlitrest/pkg/policies"
+	"github.com/cl-ment/sqlitrest/pkg/rpc"
 )
 
-// OpenAPIDoc représente la structure OpenAPI 3.0
+// OpenAPIDoc représente la structure OpenAPI 3.1
 type OpenAPIDoc struct {
 	OpenAPI    string                 `json:"openapi"`
 	Info       Info                   `json:"info"`
 	Servers    []Server               `json:"servers"`
 	Paths      map[string]interface{} `json:"paths"`
 	Components Components             `json:"components"`
+	Security   []map[string][]string  `json:"security,omitempty"`
 }
 
 // Info contient les métadonnées de l'API
@@ -42,61 +50,177 @@ type Server struct {
 }
 
 type Components struct {
-	Schemas map[string]Schema `json:"schemas"`
+	Schemas         map[string]Schema         `json:"schemas"`
+	Parameters      map[string]interface{}    `json:"parameters,omitempty"`
+	Responses       map[string]interface{}    `json:"responses,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
 }
 
 type Schema struct {
-	Type       string              `json:"type"`
-	Properties map[string]Property `json:"properties,omitempty"`
-	Required   []string            `json:"required,omitempty"`
+	Type        string              `json:"type"`
+	Description string              `json:"description,omitempty"`
+	Properties  map[string]Property `json:"properties,omitempty"`
+	Required    []string            `json:"required,omitempty"`
 }
 
+// Property décrit une colonne ; les contraintes viennent du DDL de la table
 type Property struct {
-	Type        string      `json:"type"`
-	Format      string      `json:"format,omitempty"`
-	Description string      `json:"description,omitempty"`
-	Example     interface{} `json:"example,omitempty"`
+	Type             interface{}   `json:"type"` // type ou [type, "null"] pour une colonne nullable
+	Format           string        `json:"format,omitempty"`
+	Description      string        `json:"description,omitempty"`
+	Default          interface{}   `json:"default,omitempty"`
+	Enum             []interface{} `json:"enum,omitempty"`
+	Minimum          *float64      `json:"minimum,omitempty"`
+	Maximum          *float64      `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64      `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64      `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int          `json:"minLength,omitempty"`
+	MaxLength        *int          `json:"maxLength,omitempty"`
+	ReadOnly         bool          `json:"readOnly,omitempty"` // colonne générée
+	Collation        string        `json:"x-collation,omitempty"`
+}
+
+// SecurityScheme décrit un mode d'authentification
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
 }
 
-// OpenAPIGenerator génère la spécification OpenAPI depuis le schéma DB
+// OpenAPIGenerator génère la spécification OpenAPI d'une base depuis son SchemaCache
 type OpenAPIGenerator struct {
-	db *sql.DB
+	dbName     string
+	cache      *engine.SchemaCache
+	rpc        *rpc.RPCHandler
+	grants     *auth.GrantManager
+	policies   *policies.PolicyEngine
+	serverURL  string
+	readOnly   bool
+	jwtEnabled bool
+
+	docs        map[string]*OpenAPIDoc // documents générés par rôle
+	docsVersion string                 // état (schéma, politiques, fonctions) des documents en cache
+	mutex       sync.RWMutex
+}
+
+// NewOpenAPIGenerator crée un générateur pour la base dbName
+func NewOpenAPIGenerator(dbName string, cache *engine.SchemaCache) *OpenAPIGenerator {
+	return &OpenAPIGenerator{
+		dbName:    dbName,
+		cache:     cache,
+		serverURL: "http://localhost:34334",
+		docs:      make(map[string]*OpenAPIDoc),
+	}
+}
+
+// SetRPCHandler ajoute les fonctions RPC (SQL et natives) à la spécification
+func (g *OpenAPIGenerator) SetRPCHandler(handler *rpc.RPCHandler) {
+	g.rpc = handler
+}
+
+// SetAccessControl limite chaque document aux tables, opérations et fonctions
+// que le rôle de l'appelant peut utiliser
+func (g *OpenAPIGenerator) SetAccessControl(grants *auth.GrantManager, policyEngine *policies.PolicyEngine) {
+	g.grants = grants
+	g.policies = policyEngine
+}
+
+// SetServerURL définit l'URL publique du serveur
+func (g *OpenAPIGenerator) SetServerURL(url string) {
+	g.serverURL = strings.TrimRight(url, "/")
+}
+
+// SetReadOnly limite les opérations décrites à la lecture (base en lecture seule)
+func (g *OpenAPIGenerator) SetReadOnly(readOnly bool) {
+	g.readOnly = readOnly
 }
 
-// NewOpenAPIGenerator crée un nouveau générateur OpenAPI
-func NewOpenAPIGenerator(db *sql.DB) *OpenAPIGenerator {
-	return &OpenAPIGenerator{db: db}
+// SetJWTEnabled ajoute l'authentification Bearer JWT aux schémas de sécurité
+func (g *OpenAPIGenerator) SetJWTEnabled(enabled bool) {
+	g.jwtEnabled = enabled
+}
+
+// Generate retourne la spécification OpenAPI visible par l'appelant. Les documents
+// sont mis en cache par rôle jusqu'au prochain changement de schéma, de politiques
+// ou de fonctions RPC, sauf si des politiques dépendent de l'identité de l'appelant
+// (utilisateur, tenant, claims) : le document est alors généré à chaque appel.
+// Un contexte nil équivaut à un appelant non authentifié.
+func (g *OpenAPIGenerator) Generate(authCtx *auth.AuthContext) (*OpenAPIDoc, error) {
+	if authCtx == nil {
+		authCtx = &auth.AuthContext{Role: "anonymous"}
+	}
+	version, err := g.stateVersion()
+	if err != nil {
+		return nil, err
+	}
+	if g.policies != nil && g.policies.DependsOnIdentity() {
+		return g.generate(authCtx)
+	}
+	key := roleKey(authCtx)
+
+	g.mutex.RLock()
+	doc, exists := g.docs[key]
+	current := g.docsVersion == version
+	g.mutex.RUnlock()
+	if exists && current {
+		return doc, nil
+	}
+
+	doc, err = g.generate(authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.docsVersion != version {
+		g.docs = make(map[string]*OpenAPIDoc)
+		g.docsVersion = version
+	}
+	g.docs[key] = doc
+	return doc, nil
 }
 
-// Generate génère la spécification OpenAPI complète
-func (g *OpenAPIGenerator) Generate() (*OpenAPIDoc, error) {
-	// Récupérer les tables
-	tables, err := g.getTables()
+// generate génère la spécification pour un contexte d'authentification
+func (g *OpenAPIGenerator) generate(authCtx *auth.AuthContext) (*OpenAPIDoc, error) {
+	// Récupérer les tables et leurs schémas
+	allTables, err := g.cache.ListTables()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tables: %w", err)
 	}
 
-	// Générer les schémas
-	schemas := make(map[string]Schema)
-	for _, table := range tables {
-		schema, err := g.generateTableSchema(table)
+	// Ne garder que les tables sur lesquelles le rôle a au moins un droit
+	var tables []string
+	infos := make(map[string]*engine.SchemaInfo, len(allTables))
+	access := make(map[string]tableAccess, len(allTables))
+	for _, table := range allTables {
+		info, err := g.cache.GetSchema(table)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate schema for %s: %w", table, err)
+			return nil, fmt.Errorf("failed to load schema for %s: %w", table, err)
+		}
+		tableAccess := g.tableAccessFor(authCtx, table, !g.readOnly && !info.IsView)
+		if !tableAccess.any() {
+			continue
 		}
-		schemas[table] = *schema
+		tables = append(tables, table)
+		infos[table] = info
+		access[table] = tableAccess
 	}
 
-	// Générer les paths
-	paths, err := g.generatePaths(tables)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate paths: %w", err)
+	// Générer les schémas
+	schemas := make(map[string]Schema)
+	for _, table := range tables {
+		schemas[table] = g.generateTableSchema(infos[table], infos)
 	}
 
 	doc := &OpenAPIDoc{
-		OpenAPI: "3.0.0",
+		OpenAPI: "3.1.0",
 		Info: Info{
-			Title:       "SQLitREST API",
-			Description: "RESTful API for SQLite database with PostgREST compatibility",
+			Title:       fmt.Sprintf("SQLitREST API - %s", g.dbName),
+			Description: fmt.Sprintf("RESTful API for the SQLite database %s with PostgREST compatibility", g.dbName),
 			Version:     "1.0.0",
 			Contact: Contact{
 				Name:  "SQLitREST Team",
@@ -109,87 +233,214 @@ func (g *OpenAPIGenerator) Generate() (*OpenAPIDoc, error) {
 		},
 		Servers: []Server{
 			{
-				URL:         "http://localhost:34334",
-				Description: "Development server",
+				URL:         g.serverURL,
+				Description: "SQLitREST server",
 			},
 		},
-		Paths:      paths,
-		Components: Components{Schemas: schemas},
+		Paths: g.generatePaths(authCtx, tables, infos, schemas, access),
+		Components: Components{
+			Schemas:         schemas,
+			Parameters:      g.generateParameters(tables, infos),
+			Responses:       commonResponses(),
+			SecuritySchemes: g.securitySchemes(),
+		},
+		Security: g.securityRequirements(),
 	}
 
 	return doc, nil
 }
 
-// getTables récupère la liste des tables
-func (g *OpenAPIGenerator) getTables() ([]string, error) {
-	rows, err := g.db.Query("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE '_%'")
-	if err != nil {
-		return nil, err
+// generateTableSchema génère le schéma d'une table et décrit ses relations
+func (g *OpenAPIGenerator) generateTableSchema(info *engine.SchemaInfo, infos map[string]*engine.SchemaInfo) Schema {
+	properties := make(map[string]Property)
+	var required []string
+
+	for _, column := range info.Columns {
+		openAPIType := g.mapSQLiteTypeToOpenAPI(column.Type)
+		property := Property{
+			Type:        openAPIType,
+			Description: fmt.Sprintf("Column %s of type %s", column.Name, column.Type),
+		}
+		if !column.NotNull && !column.IsPrimaryKey {
+			property.Type = []string{openAPIType, "null"}
+		}
+		if column.IsPrimaryKey {
+			property.Description += ". Primary key"
+		}
+		if fk, exists := info.ForeignKeys[column.Name]; exists && infos[fk.PrimaryTable] != nil {
+			property.Description += fmt.Sprintf(". Foreign key to %s.%s", fk.PrimaryTable, fk.PrimaryColumn)
+		}
+
+		g.applyConstraints(&property, column, openAPIType)
+
+		properties[column.Name] = property
+
+		if column.RequiredOnInsert() {
+			required = append(required, column.Name)
+		}
 	}
-	defer rows.Close()
 
-	var tables []string
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, err
+	description := describeRelations(info, infos)
+	if len(info.Checks) > 0 {
+		if description != "" {
+			description += ". "
 		}
-		tables = append(tables, tableName)
+		description += "Checks: " + strings.Join(info.Checks, "; ")
 	}
 
-	return tables, nil
+	return Schema{
+		Type:        "object",
+		Description: description,
+		Properties:  properties,
+		Required:    required,
+	}
 }
 
-// generateTableSchema génère le schéma pour une table
-func (g *OpenAPIGenerator) generateTableSchema(tableName string) (*Schema, error) {
-	rows, err := g.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
-	if err != nil {
-		return nil, err
+// applyConstraints reporte le défaut, les CHECK reconnus (énumération, bornes,
+// longueurs), la collation et l'expression générée d'une colonne sur sa propriété
+func (g *OpenAPIGenerator) applyConstraints(property *Property, column engine.ColumnInfo, openAPIType string) {
+	if column.DefaultValue != "" {
+		if value, ok := defaultLiteral(column.DefaultValue, openAPIType); ok {
+			property.Default = value
+		} else {
+			property.Description += fmt.Sprintf(". Default: %s", column.DefaultValue)
+		}
 	}
-	defer rows.Close()
 
-	properties := make(map[string]Property)
-	var required []string
+	if len(column.Enum) > 0 {
+		property.Enum = append([]interface{}(nil), column.Enum...)
+		if _, nullable := property.Type.([]string); nullable {
+			property.Enum = append(property.Enum, nil)
+		}
+	}
+	property.Minimum = column.Minimum
+	property.Maximum = column.Maximum
+	property.ExclusiveMinimum = column.ExclusiveMinimum
+	property.ExclusiveMaximum = column.ExclusiveMaximum
+	property.MinLength = column.MinLength
+	property.MaxLength = column.MaxLength
+	property.Collation = column.Collation
+
+	for _, check := range column.Checks {
+		property.Description += fmt.Sprintf(". Check: %s", check)
+	}
+	if column.Generated {
+		property.ReadOnly = true
+		property.Description += ". Generated"
+		if column.Expression != "" {
+			property.Description += fmt.Sprintf(" as %s", column.Expression)
+		}
+	}
+}
 
-	for rows.Next() {
-		var cid int
-		var name, dataType string
-		var notNull, pk int
-		var defaultValue interface{}
+// defaultLiteral convertit une valeur par défaut SQL littérale en valeur JSON ;
+// les expressions (CURRENT_TIMESTAMP, fonctions) ne sont pas converties
+func defaultLiteral(value, openAPIType string) (interface{}, bool) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), true
+	}
 
-		if err := rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk); err != nil {
-			return nil, err
-		}
+	switch strings.ToUpper(value) {
+	case "TRUE":
+		return true, true
+	case "FALSE":
+		return false, true
+	}
 
-		property := Property{
-			Type:        g.mapSQLiteTypeToOpenAPI(dataType),
-			Description: fmt.Sprintf("Column %s of type %s", name, dataType),
+	switch openAPIType {
+	case "boolean":
+		switch value {
+		case "0":
+			return false, true
+		case "1":
+			return true, true
 		}
-
-		// Ajouter des exemples basés sur le type
-		switch g.mapSQLiteTypeToOpenAPI(dataType) {
-		case "string":
-			property.Example = "example text"
-		case "integer":
-			property.Example = 42
-		case "number":
-			property.Example = 3.14
-		case "boolean":
-			property.Example = true
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n, true
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n, true
 		}
+	case "string":
+		// Affinité TEXT : un défaut numérique est stocké comme texte
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// JSONSchema retourne le JSON Schema (draft 2020-12) des lignes d'une table ;
+// found est faux si la table n'existe pas
+func (g *OpenAPIGenerator) JSONSchema(table string) (schema map[string]interface{}, found bool, err error) {
+	tables, err := g.cache.ListTables()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get tables: %w", err)
+	}
+	index := sort.SearchStrings(tables, table)
+	if index == len(tables) || tables[index] != table {
+		return nil, false, nil
+	}
+
+	info, err := g.cache.GetSchema(table)
+	if err != nil {
+		return nil, false, err
+	}
+	generated := g.generateTableSchema(info, map[string]*engine.SchemaInfo{table: info})
+
+	schema = map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"$id":                  fmt.Sprintf("%s/%s/_schema/%s", g.serverURL, g.dbName, table),
+		"title":                table,
+		"type":                 "object",
+		"properties":           generated.Properties,
+		"additionalProperties": false,
+	}
+	if generated.Description != "" {
+		schema["description"] = generated.Description
+	}
+	if len(generated.Required) > 0 {
+		schema["required"] = generated.Required
+	}
+	return schema, true, nil
+}
 
-		properties[name] = property
+// describeRelations liste les relations d'une table utilisables pour l'embedding
+// (select=*,autre_table(*)) : une relation directe inclut un objet, une relation
+// inverse un tableau. Deux tables reliées par plusieurs clés étrangères ne
+// peuvent pas être incluses l'une dans l'autre et ne sont pas listées.
+func describeRelations(info *engine.SchemaInfo, infos map[string]*engine.SchemaInfo) string {
+	links := make(map[string][]string)
 
-		if notNull == 1 || pk == 1 {
-			required = append(required, name)
+	for column, fk := range info.ForeignKeys {
+		if infos[fk.PrimaryTable] == nil {
+			continue // table non visible pour ce rôle
+		}
+		links[fk.PrimaryTable] = append(links[fk.PrimaryTable],
+			fmt.Sprintf("%s(*) (object, %s references %s.%s)", fk.PrimaryTable, column, fk.PrimaryTable, fk.PrimaryColumn))
+	}
+	for name, other := range infos {
+		for column, fk := range other.ForeignKeys {
+			if strings.EqualFold(fk.PrimaryTable, info.TableName) {
+				links[name] = append(links[name],
+					fmt.Sprintf("%s(*) (array, %s.%s references %s)", name, name, column, info.TableName))
+			}
 		}
 	}
 
-	return &Schema{
-		Type:       "object",
-		Properties: properties,
-		Required:   required,
-	}, nil
+	var relations []string
+	for _, described := range links {
+		if len(described) == 1 {
+			relations = append(relations, described[0])
+		}
+	}
+	if len(relations) == 0 {
+		return ""
+	}
+	sort.Strings(relations)
+	return "Embeddable relations (select=*,table(*)): " + strings.Join(relations, "; ")
 }
 
 // mapSQLiteTypeToOpenAPI convertit les types SQLite vers OpenAPI
@@ -211,231 +462,365 @@ func (g *OpenAPIGenerator) mapSQLiteTypeToOpenAPI(sqliteType string) string {
 	return "string" // Par défaut
 }
 
-// generatePaths génère les paths OpenAPI pour les tables
-func (g *OpenAPIGenerator) generatePaths(tables []string) (map[string]interface{}, error) {
+// generatePaths génère les paths OpenAPI : /{db}/{table} et /rpc/{fonction}
+func (g *OpenAPIGenerator) generatePaths(authCtx *auth.AuthContext, tables []string, infos map[string]*engine.SchemaInfo, schemas map[string]Schema, access map[string]tableAccess) map[string]interface{} {
 	paths := make(map[string]interface{})
 
 	for _, table := range tables {
-		tablePath := fmt.Sprintf("/%s", table)
-
-		// Path item pour cette table
+		// Path item pour cette table, limité aux opérations permises
 		pathItem := make(map[string]interface{})
+		if access[table].read {
+			pathItem["get"] = g.generateGetOperation(infos[table])
+			pathItem["head"] = g.generateHeadOperation(infos[table])
+		}
+		if access[table].insert {
+			pathItem["post"] = g.generatePostOperation(table)
+		}
+		if access[table].update {
+			pathItem["patch"] = g.generatePatchOperation(infos[table], schemas[table])
+		}
+		if access[table].delete {
+			pathItem["delete"] = g.generateDeleteOperation(infos[table])
+		}
+
+		paths[fmt.Sprintf("/%s/%s", g.dbName, table)] = pathItem
+	}
 
-		// GET operation
-		pathItem["get"] = g.generateGetOperation(table)
+	if g.rpc != nil {
+		g.generateRPCPaths(authCtx, paths)
+	}
 
-		// POST operation
-		pathItem["post"] = g.generatePostOperation(table)
+	return paths
+}
 
-		// Item path (/{id})
-		itemPath := fmt.Sprintf("/%s/{id}", table)
-		itemPathItem := make(map[string]interface{})
+// generateParameters génère les paramètres partagés et un filtre par colonne
+func (g *OpenAPIGenerator) generateParameters(tables []string, infos map[string]*engine.SchemaInfo) map[string]interface{} {
+	queryParam := func(name, description, schemaType string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": description,
+			"required":    false,
+			"schema":      map[string]string{"type": schemaType},
+		}
+	}
 
-		itemPathItem["get"] = g.generateGetItemOperation(table)
-		itemPathItem["patch"] = g.generatePatchOperation(table)
-		itemPathItem["delete"] = g.generateDeleteOperation(table)
+	parameters := map[string]interface{}{
+		"select": queryParam("select", "Columns to select, with embedded relations: select=id,name,posts(title)", "string"),
+		"order":  queryParam("order", "Ordering: order=name.asc,id.desc", "string"),
+		"limit":  queryParam("limit", "Limit results", "integer"),
+		"offset": queryParam("offset", "Offset results", "integer"),
+		"cursor": queryParam("cursor", "Opaque cursor from the Link rel=\"next\" header of the previous page; empty on the first page to sort by the primary key and get a Link", "string"),
+		"or":     queryParam("or", "Logical OR of filters: or=(id.eq.1,name.eq.john)", "string"),
+		"and":    queryParam("and", "Logical AND of filters: and=(id.gt.1,age.lt.30)", "string"),
+		"range": map[string]interface{}{
+			"name":        "Range",
+			"in":          "header",
+			"description": "Rows to return, as an alternative to limit/offset: 0-24 or 25- (Range-Unit: items)",
+			"required":    false,
+			"schema":      map[string]string{"type": "string"},
+		},
+		"rangeUnit": map[string]interface{}{
+			"name":        "Range-Unit",
+			"in":          "header",
+			"description": "Unit of the Range header",
+			"required":    false,
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"items"}},
+		},
+		"preferCount": map[string]interface{}{
+			"name":        "Prefer",
+			"in":          "header",
+			"description": "Count the rows matching the filters and report the total in Content-Range",
+			"required":    false,
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"count=exact", "count=planned", "count=estimated"}},
+		},
+		"preferTx": map[string]interface{}{
+			"name":        "Prefer",
+			"in":          "header",
+			"description": "tx=rollback runs the function without keeping its side effects",
+			"required":    false,
+			"schema":      map[string]interface{}{"type": "string", "enum": []string{"tx=rollback"}},
+		},
+	}
 
-		paths[tablePath] = pathItem
-		paths[itemPath] = itemPathItem
+	for _, table := range tables {
+		for _, column := range infos[table].Columns {
+			parameters[rowFilterName(table, column.Name)] = queryParam(column.Name,
+				fmt.Sprintf("Filter on %s: eq, neq, gt, gte, lt, lte, like, ilike, in, is (e.g. %s=eq.value)", column.Name, column.Name),
+				"string")
+		}
 	}
 
-	return paths, nil
+	return parameters
 }
 
-// generateGetOperation génère l'opération GET pour une collection
-func (g *OpenAPIGenerator) generateGetOperation(table string) map[string]interface{} {
-	return map[string]interface{}{
-		"summary":     fmt.Sprintf("List %s", table),
-		"description": fmt.Sprintf("Retrieve a list of %s records", table),
-		"parameters": []map[string]interface{}{
-			{
-				"name":        "select",
-				"in":          "query",
-				"description": "Columns to select",
-				"required":    false,
-				"schema": map[string]string{
-					"type": "string",
-				},
-			},
-			{
-				"name":        "order",
-				"in":          "query",
-				"description": "Ordering",
-				"required":    false,
-				"schema": map[string]string{
-					"type": "string",
-				},
-			},
-			{
-				"name":        "limit",
-				"in":          "query",
-				"description": "Limit results",
-				"required":    false,
-				"schema": map[string]string{
-					"type": "integer",
-				},
-			},
-			{
-				"name":        "offset",
-				"in":          "query",
-				"description": "Offset results",
-				"required":    false,
-				"schema": map[string]string{
-					"type": "integer",
-				},
-			},
-		},
-		"responses": map[string]interface{}{
-			"200": map[string]interface{}{
-				"description": "Successful response",
-				"content": map[string]interface{}{
-					"application/json": map[string]interface{}{
-						"schema": map[string]interface{}{
-							"type": "array",
-							"items": map[string]string{
-								"$ref": fmt.Sprintf("#/components/schemas/%s", table),
+// rowFilterName nomme le paramètre de filtre d'une colonne
+func rowFilterName(table, column string) string {
+	return fmt.Sprintf("rowFilter.%s.%s", table, column)
+}
+
+// filterParameters référence les paramètres de filtre d'une table
+func filterParameters(info *engine.SchemaInfo) []interface{} {
+	parameters := []interface{}{ref("parameters", "or"), ref("parameters", "and")}
+	for _, column := range info.Columns {
+		parameters = append(parameters, ref("parameters", rowFilterName(info.TableName, column.Name)))
+	}
+	return parameters
+}
+
+// commonResponses décrit les réponses d'erreur partagées, au format PostgREST
+func commonResponses() map[string]interface{} {
+	nullableString := map[string]interface{}{"type": []string{"string", "null"}}
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type":     "object",
+						"required": []string{"code", "message", "details", "hint"},
+						"properties": map[string]interface{}{
+							"code":    map[string]interface{}{"type": "string", "description": "PGRST code or SQLSTATE (23505 unique violation, 23503 foreign key violation...)"},
+							"message": map[string]interface{}{"type": "string"},
+							"details": nullableString,
+							"hint":    nullableString,
+							"violations": map[string]interface{}{
+								"type":        "array",
+								"description": "Rejected request body values",
+								"items": map[string]interface{}{
+									"type": "object",
+									"properties": map[string]interface{}{
+										"column":  map[string]string{"type": "string"},
+										"message": map[string]string{"type": "string"},
+									},
+								},
 							},
 						},
 					},
 				},
 			},
+		}
+	}
+
+	return map[string]interface{}{
+		"BadRequest":          errorResponse("Invalid request"),
+		"Unauthorized":        errorResponse("Authentication failed"),
+		"Forbidden":           errorResponse("Permission denied"),
+		"Conflict":            errorResponse("Unique or foreign key constraint violation"),
+		"RangeNotSatisfiable": errorResponse("Requested range not satisfiable"),
+	}
+}
+
+// securitySchemes décrit les modes d'authentification acceptés
+func (g *OpenAPIGenerator) securitySchemes() map[string]SecurityScheme {
+	schemes := map[string]SecurityScheme{
+		"apiKeyAuth": {
+			Type:        "apiKey",
+			In:          "header",
+			Name:        "apikey",
+			Description: "API key created with sqlitrest apikey create (also accepted as Authorization: ApiKey <key>)",
 		},
 	}
+	if g.jwtEnabled {
+		schemes["bearerAuth"] = SecurityScheme{
+			Type:         "http",
+			Scheme:       "bearer",
+			BearerFormat: "JWT",
+		}
+	}
+	return schemes
 }
 
-// generatePostOperation génère l'opération POST
-func (g *OpenAPIGenerator) generatePostOperation(table string) map[string]interface{} {
+// securityRequirements : authentification optionnelle (rôle anonyme sinon)
+func (g *OpenAPIGenerator) securityRequirements() []map[string][]string {
+	requirements := []map[string][]string{{}, {"apiKeyAuth": {}}}
+	if g.jwtEnabled {
+		requirements = append(requirements, map[string][]string{"bearerAuth": {}})
+	}
+	return requirements
+}
+
+// ref construit une référence vers un composant
+func ref(kind, name string) map[string]string {
+	return map[string]string{"$ref": fmt.Sprintf("#/components/%s/%s", kind, name)}
+}
+
+// readParameters retourne les paramètres d'une lecture (GET et HEAD)
+func readParameters(info *engine.SchemaInfo) []interface{} {
+	parameters := []interface{}{
+		ref("parameters", "select"),
+		ref("parameters", "order"),
+		ref("parameters", "limit"),
+		ref("parameters", "offset"),
+		ref("parameters", "cursor"),
+		ref("parameters", "range"),
+		ref("parameters", "rangeUnit"),
+		ref("parameters", "preferCount"),
+	}
+	return append(parameters, filterParameters(info)...)
+}
+
+// rangeHeaders décrit les en-têtes de pagination d'une lecture
+func rangeHeaders() map[string]interface{} {
 	return map[string]interface{}{
-		"summary":     fmt.Sprintf("Create %s", table),
-		"description": fmt.Sprintf("Create a new %s record", table),
-		"requestBody": map[string]interface{}{
-			"required": true,
-			"content": map[string]interface{}{
-				"application/json": map[string]interface{}{
-					"schema": map[string]string{
-						"$ref": fmt.Sprintf("#/components/schemas/%s", table),
-					},
-				},
-			},
+		"Content-Range": map[string]interface{}{
+			"description": "Range of the returned rows and total when counted: 0-24/* or 0-24/100",
+			"schema":      map[string]string{"type": "string"},
 		},
-		"responses": map[string]interface{}{
-			"201": map[string]interface{}{
-				"description": "Created successfully",
-				"content": map[string]interface{}{
-					"application/json": map[string]interface{}{
-						"schema": map[string]string{
-							"$ref": fmt.Sprintf("#/components/schemas/%s", table),
-						},
-					},
-				},
-			},
+		"Link": map[string]interface{}{
+			"description": "Next page of a full cursor-paginated page: <path?cursor=...>; rel=\"next\"",
+			"schema":      map[string]string{"type": "string"},
 		},
 	}
 }
 
-// generateGetItemOperation génère l'opération GET pour un item
-func (g *OpenAPIGenerator) generateGetItemOperation(table string) map[string]interface{} {
+// generateHeadOperation génère l'opération HEAD : en-têtes d'une lecture sans les
+// lignes, pour obtenir un comptage avec Prefer: count=...
+func (g *OpenAPIGenerator) generateHeadOperation(info *engine.SchemaInfo) map[string]interface{} {
+	table := info.TableName
 	return map[string]interface{}{
-		"summary":     fmt.Sprintf("Get %s by ID", table),
-		"description": fmt.Sprintf("Retrieve a specific %s record", table),
-		"parameters": []map[string]interface{}{
-			{
-				"name":        "id",
-				"in":          "path",
-				"required":    true,
-				"description": "Record ID",
-				"schema": map[string]string{
-					"type": "integer",
-				},
-			},
+		"summary":     fmt.Sprintf("Count %s", table),
+		"description": fmt.Sprintf("Same as GET without the body: Content-Range reports the %s rows matching the filters", table),
+		"operationId": fmt.Sprintf("head_%s", table),
+		"tags":        []string{table},
+		"parameters":  readParameters(info),
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "Successful response", "headers": rangeHeaders()},
+			"206": map[string]interface{}{"description": "Partial content", "headers": rangeHeaders()},
+			"400": map[string]interface{}{"description": "Invalid query parameters"},
+			"401": map[string]interface{}{"description": "Authentication failed"},
+			"403": map[string]interface{}{"description": "Permission denied"},
+			"416": map[string]interface{}{"description": "Requested range not satisfiable", "headers": rangeHeaders()},
 		},
+	}
+}
+
+// generateGetOperation génère l'opération GET pour une collection
+func (g *OpenAPIGenerator) generateGetOperation(info *engine.SchemaInfo) map[string]interface{} {
+	table := info.TableName
+	rows := map[string]interface{}{
+		"type":  "array",
+		"items": ref("schemas", table),
+	}
+	contentRange := rangeHeaders()
+
+	return map[string]interface{}{
+		"summary":     fmt.Sprintf("List %s", table),
+		"description": fmt.Sprintf("Retrieve %s records matching the filters", table),
+		"operationId": fmt.Sprintf("list_%s", table),
+		"tags":        []string{table},
+		"parameters":  readParameters(info),
 		"responses": map[string]interface{}{
 			"200": map[string]interface{}{
 				"description": "Successful response",
+				"headers":     contentRange,
 				"content": map[string]interface{}{
-					"application/json": map[string]interface{}{
-						"schema": map[string]string{
-							"$ref": fmt.Sprintf("#/components/schemas/%s", table),
-						},
-					},
+					"application/json":                  map[string]interface{}{"schema": rows},
+					"application/vnd.pgrst.object+json": map[string]interface{}{"schema": ref("schemas", table)},
+					"text/csv":                          map[string]interface{}{"schema": map[string]string{"type": "string"}},
 				},
 			},
-			"404": map[string]interface{}{
-				"description": "Record not found",
+			"206": map[string]interface{}{
+				"description": "Partial content: the requested range covers part of the counted rows",
+				"headers":     contentRange,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": rows},
+					"text/csv":         map[string]interface{}{"schema": map[string]string{"type": "string"}},
+				},
 			},
+			"400": ref("responses", "BadRequest"),
+			"401": ref("responses", "Unauthorized"),
+			"403": ref("responses", "Forbidden"),
+			"416": ref("responses", "RangeNotSatisfiable"),
 		},
 	}
 }
 
-// generatePatchOperation génère l'opération PATCH
-func (g *OpenAPIGenerator) generatePatchOperation(table string) map[string]interface{} {
+// generatePostOperation génère l'opération POST
+func (g *OpenAPIGenerator) generatePostOperation(table string) map[string]interface{} {
 	return map[string]interface{}{
-		"summary":     fmt.Sprintf("Update %s", table),
-		"description": fmt.Sprintf("Update a specific %s record", table),
-		"parameters": []map[string]interface{}{
-			{
-				"name":        "id",
-				"in":          "path",
-				"required":    true,
-				"description": "Record ID",
-				"schema": map[string]string{
-					"type": "integer",
+		"summary":     fmt.Sprintf("Create %s", table),
+		"description": fmt.Sprintf("Insert a new %s record", table),
+		"operationId": fmt.Sprintf("create_%s", table),
+		"tags":        []string{table},
+		"requestBody": map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": ref("schemas", table),
 				},
 			},
 		},
+		"responses": map[string]interface{}{
+			"201": commandResponse("Created successfully"),
+			"400": ref("responses", "BadRequest"),
+			"401": ref("responses", "Unauthorized"),
+			"403": ref("responses", "Forbidden"),
+			"409": ref("responses", "Conflict"),
+		},
+	}
+}
+
+// generatePatchOperation génère l'opération PATCH (lignes sélectionnées par les filtres)
+func (g *OpenAPIGenerator) generatePatchOperation(info *engine.SchemaInfo, schema Schema) map[string]interface{} {
+	table := info.TableName
+	return map[string]interface{}{
+		"summary":     fmt.Sprintf("Update %s", table),
+		"description": fmt.Sprintf("Update the %s records matching the filters", table),
+		"operationId": fmt.Sprintf("update_%s", table),
+		"tags":        []string{table},
+		"parameters":  filterParameters(info),
 		"requestBody": map[string]interface{}{
 			"required": true,
 			"content": map[string]interface{}{
 				"application/json": map[string]interface{}{
-					"schema": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]string{
-							"$ref": fmt.Sprintf("#/components/schemas/%s", table),
-						},
-					},
+					// Mise à jour partielle : aucune colonne requise
+					"schema": Schema{Type: "object", Properties: schema.Properties},
 				},
 			},
 		},
 		"responses": map[string]interface{}{
-			"200": map[string]interface{}{
-				"description": "Updated successfully",
-				"content": map[string]interface{}{
-					"application/json": map[string]interface{}{
-						"schema": map[string]string{
-							"$ref": fmt.Sprintf("#/components/schemas/%s", table),
-						},
-					},
-				},
-			},
-			"404": map[string]interface{}{
-				"description": "Record not found",
-			},
+			"200": commandResponse("Updated successfully"),
+			"400": ref("responses", "BadRequest"),
+			"401": ref("responses", "Unauthorized"),
+			"403": ref("responses", "Forbidden"),
+			"409": ref("responses", "Conflict"),
 		},
 	}
 }
 
-// generateDeleteOperation génère l'opération DELETE
-func (g *OpenAPIGenerator) generateDeleteOperation(table string) map[string]interface{} {
+// generateDeleteOperation génère l'opération DELETE (lignes sélectionnées par les filtres)
+func (g *OpenAPIGenerator) generateDeleteOperation(info *engine.SchemaInfo) map[string]interface{} {
+	table := info.TableName
 	return map[string]interface{}{
 		"summary":     fmt.Sprintf("Delete %s", table),
-		"description": fmt.Sprintf("Delete a specific %s record", table),
-		"parameters": []map[string]interface{}{
-			{
-				"name":        "id",
-				"in":          "path",
-				"required":    true,
-				"description": "Record ID",
-				"schema": map[string]string{
-					"type": "integer",
-				},
-			},
-		},
+		"description": fmt.Sprintf("Delete the %s records matching the filters", table),
+		"operationId": fmt.Sprintf("delete_%s", table),
+		"tags":        []string{table},
+		"parameters":  filterParameters(info),
 		"responses": map[string]interface{}{
-			"204": map[string]interface{}{
-				"description": "Deleted successfully",
-			},
-			"404": map[string]interface{}{
-				"description": "Record not found",
+			"200": commandResponse("Deleted successfully"),
+			"400": ref("responses", "BadRequest"),
+			"401": ref("responses", "Unauthorized"),
+			"403": ref("responses", "Forbidden"),
+			"409": ref("responses", "Conflict"),
+		},
+	}
+}
+
+// commandResponse décrit la réponse d'une écriture : {"rows_affected": n, "table": "..."}
+func commandResponse(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"rows_affected": map[string]string{"type": "integer"},
+						"table":         map[string]string{"type": "string"},
+					},
+				},
 			},
 		},
 	}
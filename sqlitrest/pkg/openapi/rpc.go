@@ -0,0 +1,138 @@
+package openapi
+
+import (
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/cl-ment/sqlitrest/pkg/rpc"
+)
+
+// generateRPCPaths génère les paths /rpc/{fonction} des fonctions exécutables par l'appelant
+func (g *OpenAPIGenerator) generateRPCPaths(authCtx *auth.AuthContext, paths map[string]interface{}) {
+	for _, fn := range g.rpc.ListFunctions() {
+		if !g.allowed(authCtx, auth.PrivilegeExecute, fn.Name) {
+			continue
+		}
+		method := "post"
+		if fn.Method == "GET" {
+			method = "get"
+		}
+		paths["/rpc/"+fn.Name] = map[string]interface{}{
+			method: g.generateRPCOperation(fn),
+		}
+	}
+}
+
+// generateRPCOperation génère l'opération d'une fonction RPC : paramètres de
+// query string pour GET, corps JSON pour POST
+func (g *OpenAPIGenerator) generateRPCOperation(fn rpc.RPCFunction) map[string]interface{} {
+	params := fn.Params
+	if len(params) == 0 {
+		// Fonctions déclarées sans schéma : paramètres texte optionnels
+		for _, name := range fn.Parameters {
+			params = append(params, rpc.Param{Name: name, Type: "text"})
+		}
+	}
+
+	operation := map[string]interface{}{
+		"summary":     fn.Description,
+		"operationId": "rpc_" + fn.Name,
+		"tags":        []string{"(rpc) " + fn.Name},
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Successful response",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": rpcResultSchema(fn.Returns),
+					},
+				},
+			},
+			"400": ref("responses", "BadRequest"),
+			"401": ref("responses", "Unauthorized"),
+			"403": ref("responses", "Forbidden"),
+			"504": map[string]interface{}{
+				"description": "Function timed out",
+			},
+		},
+	}
+
+	parameters := []interface{}{ref("parameters", "preferTx")}
+	if fn.Returns == rpc.ReturnsTable {
+		parameters = append(parameters,
+			ref("parameters", "select"), ref("parameters", "order"),
+			ref("parameters", "limit"), ref("parameters", "offset"))
+	}
+
+	if fn.Method == "GET" {
+		for _, p := range params {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     p.Name,
+				"in":       "query",
+				"required": p.Required,
+				"schema":   rpcParamSchema(p),
+			})
+		}
+		operation["parameters"] = parameters
+		return operation
+	}
+	operation["parameters"] = parameters
+
+	properties := make(map[string]interface{})
+	var required []string
+	for _, p := range params {
+		properties[p.Name] = rpcParamSchema(p)
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+	body := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		body["required"] = required
+	}
+	operation["requestBody"] = map[string]interface{}{
+		"required": len(required) > 0,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": body,
+			},
+		},
+	}
+	return operation
+}
+
+// rpcParamSchema convertit le type d'un paramètre RPC en schéma OpenAPI
+func rpcParamSchema(p rpc.Param) map[string]interface{} {
+	schema := map[string]interface{}{}
+	switch p.Type {
+	case "integer":
+		schema["type"] = "integer"
+	case "real":
+		schema["type"] = "number"
+	case "boolean":
+		schema["type"] = "boolean"
+	case "json":
+		// N'importe quelle valeur JSON
+	default:
+		schema["type"] = "string"
+	}
+	if p.Default != nil {
+		schema["default"] = p.Default
+	}
+	return schema
+}
+
+// rpcResultSchema décrit le résultat d'une fonction selon sa forme de retour
+func rpcResultSchema(returns string) map[string]interface{} {
+	switch returns {
+	case rpc.ReturnsTable:
+		return map[string]interface{}{"type": "array", "items": map[string]string{"type": "object"}}
+	case rpc.ReturnsRow, "object":
+		return map[string]interface{}{"type": []string{"object", "null"}}
+	case "string", "integer", "boolean":
+		return map[string]interface{}{"type": returns}
+	case rpc.ReturnsVoid:
+		return map[string]interface{}{"type": "null"}
+	}
+	return map[string]interface{}{}
+}
@@ -2,8 +2,13 @@ package policies
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cl-ment/sqlitrest/pkg/auth"
 	"github.com/cl-ment/sqlitrest/pkg/engine"
@@ -16,12 +21,17 @@ type Policy struct {
 	Action      string `json:"action"` // SELECT, INSERT, UPDATE, DELETE
 	Expression  string `json:"expression"`
 	Description string `json:"description"`
+	Source      string `json:"source,omitempty"` // "_policies" ou chemin du fichier
 }
 
 // PolicyEngine applique les politiques de sécurité (Row Level Security)
 type PolicyEngine struct {
 	db       *sql.DB
-	policies map[string][]Policy // table -> policies
+	policies map[string][]Policy // table (minuscules) -> policies
+	version  uint64              // incrémentée à chaque rechargement
+	mutex    sync.RWMutex
+	sources  SourceConfig
+	stopChan chan struct{}
 }
 
 // NewPolicyEngine crée un nouveau moteur de politiques
@@ -32,8 +42,45 @@ func NewPolicyEngine(db *sql.DB) *PolicyEngine {
 	}
 }
 
-// LoadPolicies charge les politiques depuis la base de données
+// SetSources configure les fichiers de politiques déclaratifs
+func (e *PolicyEngine) SetSources(sources SourceConfig) {
+	e.sources = sources
+}
+
+// LoadPolicies charge les politiques depuis la base de données et les fichiers
+// déclarés, puis remplace atomiquement le jeu de politiques actif.
+// En cas d'erreur, le jeu précédent reste en place.
 func (e *PolicyEngine) LoadPolicies() error {
+	var loaded []Policy
+
+	if e.sources.Mode != ModeReplace {
+		dbPolicies, err := e.loadDBPolicies()
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, dbPolicies...)
+	}
+
+	if len(e.sources.Files) > 0 {
+		filePolicies, err := loadPolicyFiles(e.sources.Files)
+		if err != nil {
+			return err
+		}
+		loaded = mergePolicies(loaded, filePolicies)
+	}
+
+	// Un jeu contenant une expression invalide est rejeté en bloc : les
+	// politiques précédentes restent actives
+	if err := e.checkExpressions(loaded); err != nil {
+		return err
+	}
+
+	e.swapPolicies(loaded)
+	return nil
+}
+
+// loadDBPolicies charge les politiques actives de la table _policies
+func (e *PolicyEngine) loadDBPolicies() ([]Policy, error) {
 	// Créer la table des politiques si elle n'existe pas
 	createTableSQL := `
 	CREATE TABLE IF NOT EXISTS _policies (
@@ -48,68 +95,269 @@ func (e *PolicyEngine) LoadPolicies() error {
 	);`
 
 	if _, err := e.db.Exec(createTableSQL); err != nil {
-		return fmt.Errorf("failed to create policies table: %w", err)
+		return nil, fmt.Errorf("failed to create policies table: %w", err)
 	}
 
 	// Charger les politiques actives
-	rows, err := e.db.Query("SELECT name, table_name, action, expression, description FROM _policies WHERE enabled = TRUE")
+	rows, err := e.db.Query("SELECT name, table_name, action, expression, COALESCE(description, '') FROM _policies WHERE enabled = TRUE")
 	if err != nil {
-		return fmt.Errorf("failed to load policies: %w", err)
+		return nil, fmt.Errorf("failed to load policies: %w", err)
 	}
 	defer rows.Close()
 
-	e.policies = make(map[string][]Policy)
+	var policies []Policy
 	for rows.Next() {
-		var policy Policy
+		policy := Policy{Source: "_policies"}
 		err := rows.Scan(&policy.Name, &policy.Table, &policy.Action, &policy.Expression, &policy.Description)
 		if err != nil {
-			return fmt.Errorf("failed to scan policy: %w", err)
+			return nil, fmt.Errorf("failed to scan policy: %w", err)
 		}
 
-		e.policies[policy.Table] = append(e.policies[policy.Table], policy)
+		policies = append(policies, policy)
 	}
 
-	return nil
+	return policies, rows.Err()
+}
+
+// swapPolicies remplace le jeu de politiques actif et journalise les différences
+func (e *PolicyEngine) swapPolicies(loaded []Policy) {
+	next := make(map[string][]Policy)
+	for _, policy := range loaded {
+		key := strings.ToLower(policy.Table)
+		next[key] = append(next[key], policy)
+	}
+
+	e.mutex.Lock()
+	previous := e.policies
+	e.policies = next
+	e.version++
+	e.mutex.Unlock()
+
+	for _, line := range diffPolicies(previous, next) {
+		log.Printf("Policies: %s", line)
+	}
+}
+
+// ListPolicies retourne une copie des politiques actives
+func (e *PolicyEngine) ListPolicies() []Policy {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	var policies []Policy
+	for _, tablePolicies := range e.policies {
+		policies = append(policies, tablePolicies...)
+	}
+	return policies
 }
 
-// ApplyPolicies applique les politiques de sécurité à une requête SQL
-func (e *PolicyEngine) ApplyPolicies(query string, params *engine.QueryParameters, authCtx *auth.AuthContext) (string, []interface{}, error) {
-	table := params.Table
+// DependsOnIdentity indique si une politique active dépend de l'utilisateur, du
+// tenant ou des claims de l'appelant, et pas seulement de son rôle
+func (e *PolicyEngine) DependsOnIdentity() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	for _, tablePolicies := range e.policies {
+		for _, policy := range tablePolicies {
+			for _, function := range []string{"current_user_id()", "current_tenant_id()", "current_claims()"} {
+				if strings.Contains(policy.Expression, function) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Version change à chaque rechargement des politiques
+func (e *PolicyEngine) Version() uint64 {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.version
+}
+
+// StartWatching surveille les fichiers de politiques et recharge le jeu
+// de politiques à chaque modification
+func (e *PolicyEngine) StartWatching() {
+	if !e.sources.Watch || len(e.sources.Files) == 0 || e.stopChan != nil {
+		return
+	}
+
+	interval := defaultWatchInterval
+	if e.sources.WatchInterval != "" {
+		if d, err := time.ParseDuration(e.sources.WatchInterval); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("Invalid policies watch_interval %q, using %s", e.sources.WatchInterval, interval)
+		}
+	}
+
+	fingerprint, _ := fingerprintFiles(e.sources.Files)
+	e.stopChan = make(chan struct{})
+	go e.watchFiles(fingerprint, interval, e.stopChan)
+}
+
+// StopWatching arrête la surveillance des fichiers de politiques
+func (e *PolicyEngine) StopWatching() {
+	if e.stopChan != nil {
+		close(e.stopChan)
+		e.stopChan = nil
+	}
+}
+
+// ApplyPolicies applique les politiques de sécurité à une requête SQL.
+// Chaque table lue (FROM, JOIN, sous-requêtes, CTE) est enveloppée par ses
+// politiques SELECT ; la cible d'un UPDATE/DELETE reçoit en plus les politiques
+// de son action dans son WHERE. Les valeurs du contexte sont insérées comme
+// littéraux échappés : la requête retournée n'ajoute aucun paramètre.
+func (e *PolicyEngine) ApplyPolicies(query string, params *engine.QueryParameters, authCtx *auth.AuthContext) (string, error) {
 	action := e.determineActionFromQuery(query)
+	parsed := parseSQL(query)
+
+	edits, err := parsed.wrapTables(func(table string) (string, error) {
+		return e.securityCondition(table, "SELECT", authCtx)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if action == "UPDATE" || action == "DELETE" {
+		condition, err := e.securityCondition(params.Table, action, authCtx)
+		if err != nil {
+			return "", err
+		}
+		if condition != "" {
+			edits = append(edits, parsed.restrictWhere(condition)...)
+		}
+	}
 
-	// Récupérer les politiques pour cette table et action
+	return parsed.render(edits), nil
+}
+
+// SecureQuery applique les politiques à une requête SQL arbitraire (fonctions RPC,
+// requêtes écrites à la main) : chaque table lue reçoit ses politiques SELECT et la
+// cible d'un UPDATE/DELETE celles de son action dans son WHERE. Un INSERT qui
+// remplace ou met à jour des lignes existantes (REPLACE, upsert) est refusé si la
+// table a des politiques UPDATE ou DELETE, qui ne peuvent pas y être appliquées.
+func (e *PolicyEngine) SecureQuery(query string, authCtx *auth.AuthContext) (string, error) {
+	parsed := parseSQL(query)
+
+	edits, err := parsed.wrapTables(func(table string) (string, error) {
+		return e.securityCondition(table, "SELECT", authCtx)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	action := parsed.statementAction()
+	if action == "" || action == "SELECT" {
+		return parsed.render(edits), nil
+	}
+
+	table, ok := parsed.writeTarget()
+	if !ok {
+		return "", fmt.Errorf("cannot determine the target table of %s statement", action)
+	}
+
+	switch action {
+	case "UPDATE", "DELETE":
+		condition, err := e.securityCondition(table, action, authCtx)
+		if err != nil {
+			return "", err
+		}
+		if condition != "" {
+			edits = append(edits, parsed.restrictWhere(condition)...)
+		}
+	default:
+		updates, deletes := parsed.replacesRows()
+		for _, replaced := range []struct {
+			action  string
+			applies bool
+		}{{"UPDATE", updates}, {"DELETE", deletes}} {
+			if !replaced.applies {
+				continue
+			}
+			condition, err := e.securityCondition(table, replaced.action, authCtx)
+			if err != nil {
+				return "", err
+			}
+			if condition != "" {
+				return "", fmt.Errorf("table %s has %s policies that cannot be applied to %s statement", table, replaced.action, action)
+			}
+		}
+	}
+
+	return parsed.render(edits), nil
+}
+
+// StatementAction retourne l'action d'une instruction SQL d'après ses tokens
+// (SELECT, INSERT, UPDATE, DELETE ou REPLACE), clause WITH ignorée ; VALUES est
+// une lecture. Retourne "" pour les autres instructions (PRAGMA, CREATE...).
+func StatementAction(query string) string {
+	return parseSQL(query).statementAction()
+}
+
+// securityCondition combine les politiques d'une table pour une action.
+// Retourne "" si aucune restriction ne s'applique.
+func (e *PolicyEngine) securityCondition(table, action string, authCtx *auth.AuthContext) (string, error) {
 	tablePolicies := e.getPoliciesForTable(table, action)
 	if len(tablePolicies) == 0 {
-		return query, nil, nil // Pas de politiques à appliquer
+		return "", nil // Pas de politiques à appliquer
 	}
 
-	// Construire les conditions de sécurité
 	var securityConditions []string
-	var securityArgs []interface{}
-
 	for _, policy := range tablePolicies {
-		condition, args, err := e.evaluatePolicyExpression(policy.Expression, authCtx)
+		condition, err := e.evaluatePolicyExpression(policy.Expression, authCtx)
 		if err != nil {
-			return "", nil, fmt.Errorf("failed to evaluate policy %s: %w", policy.Name, err)
+			return "", fmt.Errorf("failed to evaluate policy %s: %w", policy.Name, err)
 		}
 
+		if condition == "1=1" {
+			return "", nil // Contournement (admin)
+		}
 		if condition != "" {
-			securityConditions = append(securityConditions, condition)
-			securityArgs = append(securityArgs, args...)
+			securityConditions = append(securityConditions, fmt.Sprintf("(%s)", condition))
 		}
 	}
 
-	if len(securityConditions) == 0 {
-		return query, nil, nil
+	return strings.Join(securityConditions, " OR "), nil
+}
+
+// Restricts indique si des politiques filtrent les lignes d'une table pour une
+// action dans ce contexte ; vrai aussi quand elles ne peuvent pas être évaluées
+func (e *PolicyEngine) Restricts(table, action string, authCtx *auth.AuthContext) bool {
+	condition, err := e.securityCondition(table, action, authCtx)
+	return err != nil || condition != ""
+}
+
+// Permits indique si les politiques laissent un accès possible à une table pour
+// une action. Faux seulement quand la condition est fausse indépendamment des
+// lignes pour ce contexte (ex: current_role() = 'admin' pour un autre rôle).
+func (e *PolicyEngine) Permits(table, action string, authCtx *auth.AuthContext) bool {
+	condition, err := e.securityCondition(table, action, authCtx)
+	if err != nil {
+		return false
+	}
+	if condition == "" {
+		return true
+	}
+	// Les sous-requêtes dépendent des données : accès possible
+	if strings.Contains(strings.ToUpper(condition), "SELECT") {
+		return true
 	}
 
-	// Injecter les conditions de sécurité dans la requête
-	return e.injectSecurityConditions(query, strings.Join(securityConditions, " OR "), securityArgs)
+	// Une condition qui référence des colonnes échoue sans FROM : elle dépend des lignes
+	var allowed int
+	if err := e.db.QueryRow("SELECT CASE WHEN " + condition + " THEN 1 ELSE 0 END").Scan(&allowed); err != nil {
+		return true
+	}
+	return allowed == 1
 }
 
 // getPoliciesForTable retourne les politiques pour une table et action spécifiques
 func (e *PolicyEngine) getPoliciesForTable(table, action string) []Policy {
-	policies, exists := e.policies[table]
+	e.mutex.RLock()
+	policies, exists := e.policies[strings.ToLower(table)]
+	e.mutex.RUnlock()
 	if !exists {
 		return nil
 	}
@@ -128,7 +376,7 @@ func (e *PolicyEngine) getPoliciesForTable(table, action string) []Policy {
 func (e *PolicyEngine) determineActionFromQuery(query string) string {
 	query = strings.TrimSpace(strings.ToUpper(query))
 
-	if strings.HasPrefix(query, "SELECT") {
+	if strings.HasPrefix(query, "SELECT") || strings.HasPrefix(query, "WITH") {
 		return "SELECT"
 	} else if strings.HasPrefix(query, "INSERT") {
 		return "INSERT"
@@ -141,27 +389,26 @@ func (e *PolicyEngine) determineActionFromQuery(query string) string {
 	return "UNKNOWN"
 }
 
-// evaluatePolicyExpression évalue une expression de politique avec le contexte d'authentification
-func (e *PolicyEngine) evaluatePolicyExpression(expression string, authCtx *auth.AuthContext) (string, []interface{}, error) {
+// evaluatePolicyExpression évalue une expression de politique avec le contexte
+// d'authentification ; les fonctions contextuelles sont remplacées par des littéraux
+func (e *PolicyEngine) evaluatePolicyExpression(expression string, authCtx *auth.AuthContext) (string, error) {
 	// Évaluer les conditions simples d'abord
 	if authCtx.Authenticated && authCtx.Role == "admin" {
-		return "1=1", []interface{}{}, nil // Les admins contournent toutes les politiques
+		return "1=1", nil // Les admins contournent toutes les politiques
 	}
 
-	var args []interface{}
-
 	// Substitution directe des fonctions contextuelles par les valeurs réelles
 	if strings.Contains(expression, "current_user_id()") {
-		if authCtx.Authenticated {
-			expression = strings.ReplaceAll(expression, "current_user_id()", authCtx.UserID)
+		if authCtx.Authenticated && authCtx.UserID != "" {
+			expression = strings.ReplaceAll(expression, "current_user_id()", userIDLiteral(authCtx.UserID))
 		} else {
 			expression = strings.ReplaceAll(expression, "current_user_id()", "NULL")
 		}
 	}
 
 	if strings.Contains(expression, "current_role()") {
-		if authCtx.Authenticated {
-			expression = strings.ReplaceAll(expression, "current_role()", fmt.Sprintf("'%s'", authCtx.Role))
+		if authCtx.Role != "" {
+			expression = strings.ReplaceAll(expression, "current_role()", quoteLiteral(authCtx.Role))
 		} else {
 			expression = strings.ReplaceAll(expression, "current_role()", "'anonymous'")
 		}
@@ -169,77 +416,38 @@ func (e *PolicyEngine) evaluatePolicyExpression(expression string, authCtx *auth
 
 	if strings.Contains(expression, "current_tenant_id()") {
 		if authCtx.Authenticated && authCtx.TenantID != "" {
-			expression = strings.ReplaceAll(expression, "current_tenant_id()", fmt.Sprintf("'%s'", authCtx.TenantID))
+			expression = strings.ReplaceAll(expression, "current_tenant_id()", quoteLiteral(authCtx.TenantID))
 		} else {
 			expression = strings.ReplaceAll(expression, "current_tenant_id()", "NULL")
 		}
 	}
 
-	return expression, args, nil
-}
-
-// injectSecurityConditions injecte les conditions de sécurité dans une requête SQL
-func (e *PolicyEngine) injectSecurityConditions(query, securityCondition string, securityArgs []interface{}) (string, []interface{}, error) {
-	// Pour les requêtes SELECT, injecter dans WHERE
-	if strings.HasPrefix(strings.ToUpper(query), "SELECT") {
-		if strings.Contains(strings.ToUpper(query), " WHERE ") {
-			// Il y a déjà une clause WHERE, ajouter avec AND
-			query = fmt.Sprintf("%s AND (%s)", query, securityCondition)
-		} else {
-			// Pas de clause WHERE, en ajouter une
-			// Trouver où insérer (après FROM mais avant ORDER/LIMIT/OFFSET)
-			query = e.insertWhereAfterFrom(query, securityCondition)
+	// Claims bruts du token, exploitables avec json_extract(current_claims(), '$.email')
+	if strings.Contains(expression, "current_claims()") {
+		claims := "{}"
+		if authCtx.Authenticated && authCtx.Claims != nil {
+			if data, err := json.Marshal(authCtx.Claims); err == nil {
+				claims = string(data)
+			}
 		}
-		return query, securityArgs, nil
+		expression = strings.ReplaceAll(expression, "current_claims()", quoteLiteral(claims))
 	}
 
-	// Pour UPDATE et DELETE, injecter dans WHERE
-	if strings.HasPrefix(strings.ToUpper(query), "UPDATE") || strings.HasPrefix(strings.ToUpper(query), "DELETE") {
-		if strings.Contains(strings.ToUpper(query), " WHERE ") {
-			query = fmt.Sprintf("%s AND (%s)", query, securityCondition)
-		} else {
-			// Pour UPDATE/DELETE sans WHERE, ajouter WHERE pour sécurité
-			query = fmt.Sprintf("%s WHERE %s", query, securityCondition)
-		}
-		return query, securityArgs, nil
-	}
-
-	// Pour INSERT, pas de modification (les politiques INSERT gèrent les colonnes)
-	return query, securityArgs, nil
+	return expression, nil
 }
 
-// insertWhereAfterFrom insère une clause WHERE après FROM
-func (e *PolicyEngine) insertWhereAfterFrom(query, condition string) string {
-	// Simplifié : trouver la fin de FROM et insérer WHERE
-	upperQuery := strings.ToUpper(query)
-	fromIndex := strings.Index(upperQuery, " FROM ")
-	if fromIndex == -1 {
-		return query
-	}
-
-	// Trouver la fin de la clause FROM (prochain WHERE, ORDER, LIMIT, etc.)
-	afterFrom := query[fromIndex+6:] // +6 pour " FROM "
-
-	// Chercher les clauses qui suivent
-	clauses := []string{" WHERE ", " ORDER BY ", " GROUP BY ", " HAVING ", " LIMIT ", " OFFSET "}
-	insertPos := len(afterFrom)
-
-	for _, clause := range clauses {
-		if pos := strings.Index(strings.ToUpper(afterFrom), clause); pos != -1 && pos < insertPos {
-			insertPos = pos
-		}
-	}
+// quoteLiteral échappe une valeur en littéral chaîne SQL
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
 
-	if insertPos == len(afterFrom) {
-		// Pas d'autres clauses, ajouter à la fin
-		return query + " WHERE " + condition
+// userIDLiteral conserve les identifiants numériques tels quels et échappe les autres
+// (ex: "auth0|abc" issu du claim sub)
+func userIDLiteral(userID string) string {
+	if _, err := strconv.ParseInt(userID, 10, 64); err == nil {
+		return userID
 	}
-
-	// Insérer avant la clause trouvée
-	beforeClause := afterFrom[:insertPos]
-	afterClause := afterFrom[insertPos:]
-
-	return query[:fromIndex+6] + beforeClause + " WHERE " + condition + afterClause
+	return quoteLiteral(userID)
 }
 
 // CreateDefaultPolicies crée les politiques par défaut pour la démo
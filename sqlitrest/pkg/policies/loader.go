@@ -0,0 +1,309 @@
+package policies
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ModeMerge combine les politiques de _policies et des fichiers (les fichiers l'emportent)
+	ModeMerge = "merge"
+	// ModeReplace ignore _policies et n'utilise que les fichiers
+	ModeReplace = "replace"
+
+	defaultWatchInterval = 2 * time.Second
+)
+
+// SourceConfig décrit les fichiers de politiques déclaratifs
+type SourceConfig struct {
+	Files         []string `toml:"files"`          // chemins ou motifs glob (.toml, .yaml, .yml)
+	Mode          string   `toml:"mode"`           // "merge" (défaut) ou "replace"
+	Watch         bool     `toml:"watch"`          // recharger à chaud les fichiers modifiés
+	WatchInterval string   `toml:"watch_interval"` // durée entre deux vérifications, ex: "2s"
+}
+
+// policyFile représente le contenu d'un fichier de politiques
+type policyFile struct {
+	Policies []filePolicy `toml:"policies" yaml:"policies"`
+}
+
+// filePolicy représente une politique déclarée dans un fichier
+type filePolicy struct {
+	Name        string `toml:"name" yaml:"name"`
+	Table       string `toml:"table" yaml:"table"`
+	Action      string `toml:"action" yaml:"action"`
+	Expression  string `toml:"expression" yaml:"expression"`
+	Description string `toml:"description" yaml:"description"`
+	Enabled     *bool  `toml:"enabled" yaml:"enabled"`
+}
+
+// resolvePolicyFiles développe les motifs glob en liste de fichiers triée
+func resolvePolicyFiles(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy file pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("policy file %s not found", pattern)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// loadPolicyFiles charge et valide toutes les politiques déclarées dans les fichiers.
+// Un seul fichier invalide fait échouer l'ensemble du chargement.
+func loadPolicyFiles(patterns []string) ([]Policy, error) {
+	files, err := resolvePolicyFiles(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	var policies []Policy
+
+	for _, path := range files {
+		filePolicies, err := parsePolicyFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, policy := range filePolicies {
+			if other, exists := names[policy.Name]; exists {
+				return nil, fmt.Errorf("%s: duplicate policy %s (already declared in %s)", path, policy.Name, other)
+			}
+			names[policy.Name] = path
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies, nil
+}
+
+// parsePolicyFile lit un fichier TOML ou YAML de politiques
+func parsePolicyFile(path string) ([]Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", path, err)
+	}
+
+	var file policyFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported policy file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+
+	var policies []Policy
+	for i, fp := range file.Policies {
+		if fp.Enabled != nil && !*fp.Enabled {
+			continue
+		}
+
+		policy := Policy{
+			Name:        strings.TrimSpace(fp.Name),
+			Table:       strings.TrimSpace(fp.Table),
+			Action:      strings.ToUpper(strings.TrimSpace(fp.Action)),
+			Expression:  strings.TrimSpace(fp.Expression),
+			Description: fp.Description,
+			Source:      path,
+		}
+
+		if err := validatePolicy(policy); err != nil {
+			return nil, fmt.Errorf("%s: policy #%d: %w", path, i+1, err)
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// validatePolicy vérifie qu'une politique est complète
+func validatePolicy(policy Policy) error {
+	if policy.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if policy.Table == "" {
+		return fmt.Errorf("policy %s: table is required", policy.Name)
+	}
+	if policy.Expression == "" {
+		return fmt.Errorf("policy %s: expression is required", policy.Name)
+	}
+
+	switch policy.Action {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "ALL":
+		return nil
+	default:
+		return fmt.Errorf("policy %s: invalid action %q", policy.Name, policy.Action)
+	}
+}
+
+// checkExpressions compile chaque expression sur sa table, fonctions contextuelles
+// remplacées, pour détecter les erreurs SQL avant l'activation des politiques.
+// Les tables absentes ne sont pas vérifiées : aucune requête ne peut les lire.
+func (e *PolicyEngine) checkExpressions(policies []Policy) error {
+	if e.db == nil {
+		return nil
+	}
+
+	placeholder := &auth.AuthContext{Authenticated: true, UserID: "0", Role: "policy_check", TenantID: "0", Claims: map[string]interface{}{}}
+	for _, policy := range policies {
+		var exists int
+		if err := e.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type IN ('table', 'view') AND name = ? COLLATE NOCASE", policy.Table).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check policy %s: %w", policy.Name, err)
+		}
+		if exists == 0 {
+			continue
+		}
+
+		condition, err := e.evaluatePolicyExpression(policy.Expression, placeholder)
+		if err != nil {
+			return fmt.Errorf("policy %s: %w", policy.Name, err)
+		}
+		// LIMIT 0 : la requête est compilée sans lire de ligne (le pilote ne
+		// compile qu'à l'exécution, Prepare ne suffit pas)
+		rows, err := e.db.Query(fmt.Sprintf("SELECT 1 FROM %s WHERE (%s) LIMIT 0", quoteIdentifier(policy.Table), condition))
+		if err == nil {
+			err = rows.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("policy %s (%s): invalid expression: %w", policy.Name, policy.Source, err)
+		}
+	}
+	return nil
+}
+
+// mergePolicies fusionne les politiques des fichiers dans celles de la base.
+// Une politique de fichier remplace une politique de base portant le même nom.
+func mergePolicies(base, overrides []Policy) []Policy {
+	overridden := make(map[string]bool)
+	for _, policy := range overrides {
+		overridden[policy.Name] = true
+	}
+
+	var merged []Policy
+	for _, policy := range base {
+		if !overridden[policy.Name] {
+			merged = append(merged, policy)
+		}
+	}
+
+	return append(merged, overrides...)
+}
+
+// diffPolicies décrit les politiques ajoutées, supprimées et modifiées
+func diffPolicies(previous, next map[string][]Policy) []string {
+	index := func(set map[string][]Policy) map[string]Policy {
+		byName := make(map[string]Policy)
+		for _, policies := range set {
+			for _, policy := range policies {
+				byName[policy.Name] = policy
+			}
+		}
+		return byName
+	}
+
+	before := index(previous)
+	after := index(next)
+
+	var lines []string
+	for name, policy := range after {
+		old, exists := before[name]
+		switch {
+		case !exists:
+			lines = append(lines, fmt.Sprintf("+ %s on %s (%s): %s [%s]", name, policy.Table, policy.Action, policy.Expression, policy.Source))
+		case old.Table != policy.Table || old.Action != policy.Action || old.Expression != policy.Expression:
+			lines = append(lines, fmt.Sprintf("~ %s on %s (%s): %s -> %s [%s]", name, policy.Table, policy.Action, old.Expression, policy.Expression, policy.Source))
+		}
+	}
+	for name, policy := range before {
+		if _, exists := after[name]; !exists {
+			lines = append(lines, fmt.Sprintf("- %s on %s (%s) [%s]", name, policy.Table, policy.Action, policy.Source))
+		}
+	}
+
+	sort.Strings(lines)
+	return lines
+}
+
+// fingerprintFiles calcule une empreinte du contenu des fichiers de politiques
+func fingerprintFiles(patterns []string) (string, error) {
+	files, err := resolvePolicyFiles(patterns)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(path))
+		hash.Write([]byte{0})
+		hash.Write(data)
+		hash.Write([]byte{0})
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// watchFiles recharge les politiques quand le contenu des fichiers change
+func (e *PolicyEngine) watchFiles(last string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			current, err := fingerprintFiles(e.sources.Files)
+			if err != nil {
+				if current != last {
+					log.Printf("Policy files unreadable, keeping previous policies: %v", err)
+					last = current
+				}
+				continue
+			}
+			if current == last {
+				continue
+			}
+			last = current
+
+			if err := e.LoadPolicies(); err != nil {
+				log.Printf("Rejected policy reload, keeping previous policies: %v", err)
+				continue
+			}
+			log.Printf("Policies reloaded from files")
+		}
+	}
+}
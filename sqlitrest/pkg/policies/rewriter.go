@@ -0,0 +1,592 @@
+package policies
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tokenKind représente la nature d'un token SQL
+type tokenKind int
+
+const (
+	tokSpace  tokenKind = iota // espaces et commentaires
+	tokWord                    // mot-clé ou identifiant nu
+	tokQuoted                  // identifiant entre "", `` ou []
+	tokString                  // littéral chaîne '...'
+	tokNumber                  // littéral numérique
+	tokParam                   // paramètre ?, ?NNN, :nom, @nom, $nom
+	tokPunct                   // ponctuation et opérateurs
+)
+
+// sqlToken est un token SQL avec son texte source exact
+type sqlToken struct {
+	kind tokenKind
+	text string
+}
+
+// tableRef représente une référence de table lue (FROM, JOIN, liste FROM)
+type tableRef struct {
+	Name      string // nom de la table sans schéma ni guillemets
+	Qualified bool   // référence préfixée par un schéma
+	start     int    // premier token de la référence (inclus)
+	end       int    // dernier token de la référence (exclu)
+	nameText  string // texte source du nom, schéma compris
+	aliasText string // texte source de l'alias, vide si absent
+	hintText  string // texte source de INDEXED BY / NOT INDEXED
+}
+
+// parsedQuery est l'arbre syntaxique minimal d'une requête SQL : la liste des
+// tokens, leur imbrication par parenthèses et les références de tables lues
+type parsedQuery struct {
+	tokens []sqlToken
+	sig    []int // index des tokens significatifs dans tokens
+	tables []tableRef
+	ctes   map[string]bool
+	// Positions (dans sig) du WHERE de premier niveau et de la fin de sa clause
+	where    int
+	whereEnd int
+	// Position (dans sig) du verbe de l'instruction, après une clause WITH
+	verb int
+}
+
+// clauseKeywords terminent une liste FROM
+var clauseKeywords = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true,
+	"WINDOW": true, "UNION": true, "EXCEPT": true, "INTERSECT": true,
+	"RETURNING": true, "SET": true, "VALUES": true, "ON": true, "USING": true,
+}
+
+// reservedWords ne peuvent pas être utilisés comme alias sans guillemets
+var reservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "JOIN": true, "LEFT": true,
+	"RIGHT": true, "INNER": true, "OUTER": true, "CROSS": true, "NATURAL": true,
+	"FULL": true, "ON": true, "USING": true, "GROUP": true, "ORDER": true,
+	"LIMIT": true, "OFFSET": true, "HAVING": true, "WINDOW": true, "UNION": true,
+	"EXCEPT": true, "INTERSECT": true, "RETURNING": true, "SET": true,
+	"VALUES": true, "INDEXED": true, "NOT": true, "AS": true, "WITH": true,
+	"AND": true, "OR": true, "DELETE": true, "UPDATE": true, "INSERT": true,
+}
+
+// tokenizeSQL découpe une requête SQL en tokens en conservant le texte source
+func tokenizeSQL(query string) []sqlToken {
+	var tokens []sqlToken
+	i := 0
+	n := len(query)
+
+	for i < n {
+		c := query[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			for i < n && strings.IndexByte(" \t\n\r", query[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokSpace, query[start:i]})
+		case c == '-' && i+1 < n && query[i+1] == '-':
+			for i < n && query[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokSpace, query[start:i]})
+		case c == '/' && i+1 < n && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end == -1 {
+				i = n
+			} else {
+				i += end + 4
+			}
+			tokens = append(tokens, sqlToken{tokSpace, query[start:i]})
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closer := c
+			if c == '[' {
+				closer = ']'
+			}
+			i++
+			for i < n {
+				if query[i] == closer {
+					// Guillemet doublé = guillemet échappé (sauf pour [])
+					if closer != ']' && i+1 < n && query[i+1] == closer {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			kind := tokQuoted
+			if c == '\'' {
+				kind = tokString
+			}
+			tokens = append(tokens, sqlToken{kind, query[start:i]})
+		case isWordStart(c):
+			for i < n && isWordPart(query[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokWord, query[start:i]})
+		case c >= '0' && c <= '9':
+			for i < n && (isWordPart(query[i]) || query[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokNumber, query[start:i]})
+		case c == '?' || c == ':' || c == '@' || c == '$':
+			i++
+			for i < n && isWordPart(query[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokParam, query[start:i]})
+		default:
+			i++
+			tokens = append(tokens, sqlToken{tokPunct, query[start:i]})
+		}
+	}
+
+	return tokens
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
+
+// parseSQL construit l'arbre syntaxique minimal d'une requête
+func parseSQL(query string) *parsedQuery {
+	q := &parsedQuery{
+		tokens: tokenizeSQL(query),
+		ctes:   make(map[string]bool),
+		where:  -1,
+	}
+
+	for i, tok := range q.tokens {
+		if tok.kind != tokSpace {
+			q.sig = append(q.sig, i)
+		}
+	}
+
+	q.parseSequence(0, true)
+	return q
+}
+
+// tok retourne le token significatif à la position pos
+func (q *parsedQuery) tok(pos int) sqlToken {
+	if pos < 0 || pos >= len(q.sig) {
+		return sqlToken{}
+	}
+	return q.tokens[q.sig[pos]]
+}
+
+// keyword retourne le mot à la position pos en majuscules (vide si ce n'est pas un mot)
+func (q *parsedQuery) keyword(pos int) string {
+	tok := q.tok(pos)
+	if tok.kind != tokWord {
+		return ""
+	}
+	return strings.ToUpper(tok.text)
+}
+
+// isIdentifier indique si le token à pos peut être un nom de table ou un alias
+func (q *parsedQuery) isIdentifier(pos int) bool {
+	tok := q.tok(pos)
+	switch tok.kind {
+	case tokQuoted, tokString:
+		return true
+	case tokWord:
+		return !reservedWords[strings.ToUpper(tok.text)]
+	}
+	return false
+}
+
+// identifier retourne le nom d'un identifiant sans guillemets
+func (q *parsedQuery) identifier(pos int) string {
+	text := q.tok(pos).text
+	if len(text) >= 2 {
+		switch text[0] {
+		case '"', '`', '\'':
+			quote := string(text[0])
+			return strings.ReplaceAll(text[1:len(text)-1], quote+quote, quote)
+		case '[':
+			return text[1 : len(text)-1]
+		}
+	}
+	return text
+}
+
+// text retourne le texte source entre deux positions significatives [from, to)
+func (q *parsedQuery) text(from, to int) string {
+	if from >= to {
+		return ""
+	}
+	var sb strings.Builder
+	for i := q.sig[from]; i <= q.sig[to-1]; i++ {
+		sb.WriteString(q.tokens[i].text)
+	}
+	return sb.String()
+}
+
+// parseSequence analyse une suite de tokens jusqu'à la parenthèse fermante
+// correspondante et retourne sa position (ou la fin de la requête)
+func (q *parsedQuery) parseSequence(pos int, topLevel bool) int {
+	inFrom := false
+
+	for pos < len(q.sig) {
+		tok := q.tok(pos)
+		kw := q.keyword(pos)
+
+		switch {
+		case tok.text == "(" && tok.kind == tokPunct:
+			pos = q.parseSequence(pos+1, false) + 1
+			continue
+		case tok.text == ")" && tok.kind == tokPunct:
+			if topLevel && q.where >= 0 && q.whereEnd < 0 {
+				q.whereEnd = pos
+			}
+			return pos
+		case kw == "WITH":
+			start := pos
+			pos = q.parseWith(pos + 1)
+			if topLevel && start == 0 {
+				q.verb = pos
+			}
+			continue
+		case kw == "FROM":
+			if q.keyword(pos-1) == "DELETE" {
+				// Cible d'un DELETE : soumise aux politiques DELETE, pas SELECT
+				pos++
+				continue
+			}
+			inFrom = true
+			pos = q.parseTableRef(pos + 1)
+			continue
+		case kw == "JOIN":
+			inFrom = true
+			pos = q.parseTableRef(pos + 1)
+			continue
+		case tok.text == "," && tok.kind == tokPunct && inFrom:
+			pos = q.parseTableRef(pos + 1)
+			continue
+		case tok.text == ";" && tok.kind == tokPunct:
+			inFrom = false
+			if topLevel && q.where >= 0 && q.whereEnd < 0 {
+				q.whereEnd = pos
+			}
+		case clauseKeywords[kw]:
+			if kw != "ON" && kw != "USING" {
+				inFrom = false
+			}
+			if topLevel {
+				if kw == "WHERE" && q.where < 0 {
+					q.where = pos
+					q.whereEnd = -1
+				} else if q.where >= 0 && q.whereEnd < 0 && kw != "SET" && kw != "ON" && kw != "USING" {
+					q.whereEnd = pos
+				}
+			}
+		}
+
+		pos++
+	}
+
+	if topLevel && q.where >= 0 && q.whereEnd < 0 {
+		q.whereEnd = len(q.sig)
+	}
+	return pos
+}
+
+// parseWith enregistre les noms des CTE d'une clause WITH
+func (q *parsedQuery) parseWith(pos int) int {
+	if q.keyword(pos) == "RECURSIVE" {
+		pos++
+	}
+
+	for {
+		if !q.isIdentifier(pos) {
+			return pos
+		}
+		q.ctes[strings.ToLower(q.identifier(pos))] = true
+		pos++
+
+		if q.tok(pos).text == "(" {
+			pos = q.parseSequence(pos+1, false) + 1
+		}
+		if q.keyword(pos) != "AS" {
+			return pos
+		}
+		pos++
+		if q.keyword(pos) == "NOT" {
+			pos++
+		}
+		if q.keyword(pos) == "MATERIALIZED" {
+			pos++
+		}
+		if q.tok(pos).text != "(" {
+			return pos
+		}
+		pos = q.parseSequence(pos+1, false) + 1
+
+		if q.tok(pos).text != "," {
+			return pos
+		}
+		pos++
+	}
+}
+
+// parseTableRef analyse une référence de table après FROM, JOIN ou une virgule
+func (q *parsedQuery) parseTableRef(pos int) int {
+	// Sous-requête : déjà analysée récursivement, on saute l'alias
+	if q.tok(pos).text == "(" {
+		pos = q.parseSequence(pos+1, false) + 1
+		return q.skipAlias(pos)
+	}
+
+	if !q.isIdentifier(pos) {
+		return pos
+	}
+
+	ref := tableRef{start: pos, Name: q.identifier(pos)}
+	pos++
+	if q.tok(pos).text == "." && q.isIdentifier(pos+1) {
+		ref.Name = q.identifier(pos + 1)
+		ref.Qualified = true
+		pos += 2
+	}
+
+	// Fonction table (json_each(...), etc.) : pas une table
+	if q.tok(pos).text == "(" {
+		pos = q.parseSequence(pos+1, false) + 1
+		return q.skipAlias(pos)
+	}
+	ref.nameText = q.text(ref.start, pos)
+
+	if q.keyword(pos) == "AS" && q.isIdentifier(pos+1) {
+		ref.aliasText = q.tok(pos + 1).text
+		pos += 2
+	} else if q.isIdentifier(pos) {
+		ref.aliasText = q.tok(pos).text
+		pos++
+	}
+
+	hintStart := pos
+	if q.keyword(pos) == "INDEXED" && q.keyword(pos+1) == "BY" {
+		pos += 3
+	} else if q.keyword(pos) == "NOT" && q.keyword(pos+1) == "INDEXED" {
+		pos += 2
+	}
+	ref.hintText = q.text(hintStart, pos)
+
+	ref.end = pos
+	q.tables = append(q.tables, ref)
+	return pos
+}
+
+// skipAlias saute un alias optionnel après une sous-requête ou une fonction table
+func (q *parsedQuery) skipAlias(pos int) int {
+	if q.keyword(pos) == "AS" && q.isIdentifier(pos+1) {
+		return pos + 2
+	}
+	if q.isIdentifier(pos) {
+		return pos + 1
+	}
+	return pos
+}
+
+// sqlEdit remplace les tokens [start, end) par text (insertion si start == end)
+type sqlEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// wrapTables remplace chaque référence de table par une sous-requête filtrée.
+// condition retourne la condition à appliquer à une table ("" pour ne rien faire).
+func (q *parsedQuery) wrapTables(condition func(table string) (string, error)) ([]sqlEdit, error) {
+	var edits []sqlEdit
+
+	for _, ref := range q.tables {
+		if !ref.Qualified && q.ctes[strings.ToLower(ref.Name)] {
+			continue
+		}
+
+		cond, err := condition(ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		if cond == "" {
+			continue
+		}
+
+		alias := ref.aliasText
+		if alias == "" {
+			alias = quoteIdentifier(ref.Name)
+		}
+
+		edits = append(edits, sqlEdit{
+			start: q.sig[ref.start],
+			end:   q.sig[ref.end-1] + 1,
+			text: fmt.Sprintf("(SELECT * FROM %s%s WHERE %s) AS %s",
+				ref.nameText, prefixSpace(ref.hintText), cond, alias),
+		})
+	}
+
+	return edits, nil
+}
+
+// restrictWhere ajoute une condition au WHERE de premier niveau (ou en crée un)
+func (q *parsedQuery) restrictWhere(condition string) []sqlEdit {
+	if q.where >= 0 && q.whereEnd > q.where+1 {
+		open := q.sig[q.where+1]
+		end := q.sig[q.whereEnd-1] + 1
+		return []sqlEdit{
+			{start: open, end: open, text: "("},
+			{start: end, end: end, text: fmt.Sprintf(") AND (%s)", condition)},
+		}
+	}
+
+	// Pas de WHERE : l'ajouter avant RETURNING, ORDER BY ou LIMIT de premier
+	// niveau, sinon après le dernier token significatif (hors ';')
+	last := q.clauseEnd(q.verb) - 1
+	at := 0
+	if last >= 0 {
+		at = q.sig[last] + 1
+	}
+	return []sqlEdit{{start: at, end: at, text: fmt.Sprintf(" WHERE %s", condition)}}
+}
+
+// clauseEnd retourne la position du premier RETURNING, ORDER, LIMIT ou ';' de
+// premier niveau à partir de pos, ou la fin de la requête
+func (q *parsedQuery) clauseEnd(pos int) int {
+	depth := 0
+	for ; pos < len(q.sig); pos++ {
+		tok := q.tok(pos)
+		if tok.kind == tokPunct {
+			switch tok.text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case ";":
+				if depth == 0 {
+					return pos
+				}
+			}
+			continue
+		}
+		switch q.keyword(pos) {
+		case "RETURNING", "ORDER", "LIMIT":
+			if depth == 0 {
+				return pos
+			}
+		}
+	}
+	return pos
+}
+
+// statementAction retourne le verbe de l'instruction (SELECT, INSERT, UPDATE,
+// DELETE, REPLACE), clause WITH ignorée ; VALUES est une lecture
+func (q *parsedQuery) statementAction() string {
+	switch kw := q.keyword(q.verb); kw {
+	case "SELECT", "VALUES":
+		return "SELECT"
+	case "INSERT", "UPDATE", "DELETE", "REPLACE":
+		return kw
+	}
+	return ""
+}
+
+// writeTarget retourne la table modifiée par un UPDATE, DELETE, INSERT ou
+// REPLACE ; false pour une lecture ou une cible illisible
+func (q *parsedQuery) writeTarget() (string, bool) {
+	pos := q.verb + 1
+	switch q.keyword(q.verb) {
+	case "UPDATE":
+		if q.keyword(pos) == "OR" {
+			pos += 2
+		}
+	case "DELETE":
+		if q.keyword(pos) != "FROM" {
+			return "", false
+		}
+		pos++
+	case "INSERT", "REPLACE":
+		if q.keyword(pos) == "OR" {
+			pos += 2
+		}
+		if q.keyword(pos) != "INTO" {
+			return "", false
+		}
+		pos++
+	default:
+		return "", false
+	}
+
+	if !q.isIdentifier(pos) {
+		return "", false
+	}
+	table := q.identifier(pos)
+	if q.tok(pos+1).text == "." && q.isIdentifier(pos+2) {
+		table = q.identifier(pos + 2)
+	}
+	return table, true
+}
+
+// replacesRows indique si un INSERT peut modifier ou supprimer des lignes
+// existantes : INSERT OR REPLACE, REPLACE ou upsert (ON CONFLICT DO UPDATE)
+func (q *parsedQuery) replacesRows() (updates, deletes bool) {
+	switch q.keyword(q.verb) {
+	case "REPLACE":
+		return false, true
+	case "INSERT":
+		deletes = q.keyword(q.verb+1) == "OR" && q.keyword(q.verb+2) == "REPLACE"
+	default:
+		return false, false
+	}
+	for pos := q.verb; pos < len(q.sig); pos++ {
+		if q.keyword(pos) == "DO" && q.keyword(pos+1) == "UPDATE" {
+			updates = true
+		}
+	}
+	return updates, deletes
+}
+
+// render reconstruit la requête en appliquant les modifications
+func (q *parsedQuery) render(edits []sqlEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		// Les insertions passent avant les remplacements à la même position
+		return edits[i].end-edits[i].start < edits[j].end-edits[j].start
+	})
+
+	var sb strings.Builder
+	pos := 0
+	for _, edit := range edits {
+		for ; pos < edit.start; pos++ {
+			sb.WriteString(q.tokens[pos].text)
+		}
+		sb.WriteString(edit.text)
+		if edit.end > pos {
+			pos = edit.end
+		}
+	}
+	for ; pos < len(q.tokens); pos++ {
+		sb.WriteString(q.tokens[pos].text)
+	}
+
+	return sb.String()
+}
+
+// quoteIdentifier protège un identifiant SQL avec des backticks
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func prefixSpace(s string) string {
+	if s == "" {
+		return ""
+	}
+	return " " + s
+}
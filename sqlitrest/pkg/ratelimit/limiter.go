@@ -0,0 +1,314 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/cl-ment/sqlitrest/pkg/engine"
+)
+
+// Classes de routes
+const (
+	ClassRead  = "read"  // GET/HEAD sur les tables
+	ClassWrite = "write" // POST/PATCH/PUT/DELETE sur les tables
+	ClassRPC   = "rpc"   // /rpc/*
+	ClassAuth  = "auth"  // /auth/* (login, refresh...)
+)
+
+// Clés de regroupement des requêtes
+const (
+	PerIdentity = "identity" // clé API, sinon utilisateur, sinon IP (défaut)
+	PerRole     = "role"     // un seau partagé par tout le rôle
+	PerIP       = "ip"       // adresse IP du client
+)
+
+// Config contient la configuration du limiteur de débit
+type Config struct {
+	Enabled    bool   `toml:"enabled"`
+	Persist    bool   `toml:"persist"`     // état partagé dans SQLite (plusieurs processus)
+	Database   string `toml:"database"`    // base de persistance (défaut: main)
+	TrustProxy bool   `toml:"trust_proxy"` // utiliser X-Forwarded-For / X-Real-IP
+	ProxyHops  int    `toml:"proxy_hops"`  // proxys de confiance devant le serveur (défaut: 1)
+	Rules      []Rule `toml:"rules"`
+}
+
+// Rule limite un rôle sur une classe de routes. La première règle qui
+// correspond s'applique ; sans règle, la requête n'est pas limitée.
+type Rule struct {
+	Role     string `toml:"role"`     // rôle ou "*"
+	Class    string `toml:"class"`    // read, write, rpc, auth ou "*"
+	Requests int    `toml:"requests"` // requêtes autorisées par période
+	Period   string `toml:"period"`   // défaut: 1m
+	Burst    int    `toml:"burst"`    // capacité du seau (défaut: requests)
+	Per      string `toml:"per"`      // identity, role ou ip
+}
+
+// compiledRule est une règle validée, prête à l'emploi
+type compiledRule struct {
+	Rule
+	period   time.Duration
+	capacity float64
+	rate     float64 // jetons par seconde
+}
+
+// Decision est le résultat d'une vérification de débit
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // durée avant que le seau soit plein
+	RetryAfter time.Duration // durée avant le prochain jeton (si refusé)
+	Policy     string        // en-tête RateLimit-Policy
+}
+
+// Limiter applique des limites de débit par seau à jetons
+type Limiter struct {
+	config Config
+	rules  []compiledRule
+	store  bucketStore
+}
+
+// NewLimiter crée un limiteur. db n'est utilisé que si la persistance est activée.
+func NewLimiter(config Config, db *sql.DB) (*Limiter, error) {
+	l := &Limiter{config: config}
+
+	for i, rule := range config.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit rule #%d: %w", i+1, err)
+		}
+		l.rules = append(l.rules, compiled)
+	}
+
+	if config.Persist {
+		if db == nil {
+			return nil, fmt.Errorf("rate limit persistence requires a database")
+		}
+		store, err := newSQLiteStore(db)
+		if err != nil {
+			return nil, err
+		}
+		l.store = store
+	} else {
+		l.store = newMemoryStore()
+	}
+
+	return l, nil
+}
+
+// compileRule valide une règle et calcule son débit
+func compileRule(rule Rule) (compiledRule, error) {
+	if rule.Role == "" {
+		rule.Role = "*"
+	}
+	if rule.Class == "" {
+		rule.Class = "*"
+	}
+	if rule.Per == "" {
+		rule.Per = PerIdentity
+	}
+
+	switch rule.Class {
+	case "*", ClassRead, ClassWrite, ClassRPC, ClassAuth:
+	default:
+		return compiledRule{}, fmt.Errorf("invalid class %q", rule.Class)
+	}
+	switch rule.Per {
+	case PerIdentity, PerRole, PerIP:
+	default:
+		return compiledRule{}, fmt.Errorf("invalid per %q", rule.Per)
+	}
+	if rule.Requests <= 0 {
+		return compiledRule{}, fmt.Errorf("requests must be positive")
+	}
+
+	period := time.Minute
+	if rule.Period != "" {
+		d, err := time.ParseDuration(rule.Period)
+		if err != nil || d <= 0 {
+			return compiledRule{}, fmt.Errorf("invalid period %q", rule.Period)
+		}
+		period = d
+	}
+
+	burst := rule.Burst
+	if burst <= 0 {
+		burst = rule.Requests
+	}
+
+	return compiledRule{
+		Rule:     rule,
+		period:   period,
+		capacity: float64(burst),
+		rate:     float64(rule.Requests) / period.Seconds(),
+	}, nil
+}
+
+// Close libère les ressources du limiteur
+func (l *Limiter) Close() {
+	l.store.close()
+}
+
+// Allow consomme un jeton pour la requête. Retourne nil si aucune règle ne s'applique.
+func (l *Limiter) Allow(authCtx *auth.AuthContext, class, clientIP string) *Decision {
+	rule := l.match(authCtx.Role, class)
+	if rule == nil {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s|%s|%s", rule.Role, rule.Class, bucketKey(rule.Per, authCtx, clientIP))
+	tokens, allowed, err := l.store.take(key, rule.capacity, rule.rate, time.Now())
+	if err != nil {
+		// En cas d'erreur de stockage, laisser passer plutôt que bloquer l'API
+		log.Printf("Rate limit store error: %v", err)
+		return nil
+	}
+
+	decision := &Decision{
+		Allowed:   allowed,
+		Limit:     int(rule.capacity),
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     secondsToDuration((rule.capacity - tokens) / rule.rate),
+		Policy:    fmt.Sprintf("%d;w=%d", rule.Requests, int(rule.period.Seconds())),
+	}
+	if !allowed {
+		decision.RetryAfter = secondsToDuration((1 - tokens) / rule.rate)
+	}
+	return decision
+}
+
+// match retourne la première règle applicable au rôle et à la classe
+func (l *Limiter) match(role, class string) *compiledRule {
+	for i := range l.rules {
+		rule := &l.rules[i]
+		if (rule.Role == "*" || rule.Role == role) && (rule.Class == "*" || rule.Class == class) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// bucketKey détermine le seau d'une requête selon le mode de regroupement
+func bucketKey(per string, authCtx *auth.AuthContext, clientIP string) string {
+	switch per {
+	case PerRole:
+		return "role:" + authCtx.Role
+	case PerIP:
+		return "ip:" + clientIP
+	}
+
+	if authCtx.APIKey != "" {
+		return "key:" + authCtx.APIKey
+	}
+	if authCtx.Authenticated && authCtx.UserID != "" {
+		return "user:" + authCtx.UserID
+	}
+	return "ip:" + clientIP
+}
+
+// Middleware applique le limiteur avant les handlers. La requête est authentifiée
+// une seule fois : le contexte est transmis aux handlers via le contexte de la requête.
+func (l *Limiter) Middleware(jwtManager *auth.JWTManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/health" {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			// Un token invalide est limité comme une requête anonyme ; le handler
+			// s'authentifiera à nouveau et renverra l'erreur
+			authCtx, err := jwtManager.AuthenticateRequest(req)
+			if err == nil {
+				req = req.WithContext(auth.NewContext(req.Context(), authCtx))
+			}
+
+			decision := l.Allow(authCtx, classifyRequest(req), l.clientIP(req))
+			if decision == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+			w.Header().Set("RateLimit-Policy", decision.Policy)
+
+			if !decision.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+				engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeTooManyRequests, "Rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// classifyRequest détermine la classe de route d'une requête
+func classifyRequest(req *http.Request) string {
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/rpc/"):
+		return ClassRPC
+	case strings.HasPrefix(req.URL.Path, "/auth/"):
+		return ClassAuth
+	case req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions:
+		return ClassRead
+	}
+	return ClassWrite
+}
+
+// clientIP retourne l'adresse du client, derrière un proxy de confiance si configuré.
+// Chaque proxy ajoute à droite de X-Forwarded-For l'adresse qu'il a reçue : les
+// entrées de gauche viennent du client et ne sont pas fiables. L'adresse retenue
+// est celle ajoutée par le premier proxy de confiance, proxy_hops entrées avant la fin.
+func (l *Limiter) clientIP(req *http.Request) string {
+	if l.config.TrustProxy {
+		var entries []string
+		for _, header := range req.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(header, ",") {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		if len(entries) > 0 {
+			hops := l.config.ProxyHops
+			if hops <= 0 {
+				hops = 1
+			}
+			if hops > len(entries) {
+				hops = len(entries)
+			}
+			return entries[len(entries)-hops]
+		}
+		if realIP := req.Header.Get("X-Real-IP"); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Les seaux inactifs depuis cette durée sont oubliés (ils seraient pleins)
+const idleBucketTTL = time.Hour
+
+// bucketStore conserve l'état des seaux à jetons
+type bucketStore interface {
+	// take recharge le seau puis consomme un jeton s'il en reste un.
+	// Retourne les jetons restants et si la requête est autorisée.
+	take(key string, capacity, rate float64, now time.Time) (float64, bool, error)
+	close()
+}
+
+// bucket est l'état d'un seau à jetons
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// memoryStore garde les seaux en mémoire (un seul processus)
+type memoryStore struct {
+	buckets  map[string]*bucket
+	mutex    sync.Mutex
+	stopChan chan struct{}
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		buckets:  make(map[string]*bucket),
+		stopChan: make(chan struct{}),
+	}
+	go s.cleanup()
+	return s
+}
+
+func (s *memoryStore) take(key string, capacity, rate float64, now time.Time) (float64, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tokens: capacity, updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updatedAt).Seconds()*rate)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+// cleanup oublie périodiquement les seaux inactifs
+func (s *memoryStore) cleanup() {
+	ticker := time.NewTicker(idleBucketTTL / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			s.mutex.Lock()
+			for key, b := range s.buckets {
+				if now.Sub(b.updatedAt) > idleBucketTTL {
+					delete(s.buckets, key)
+				}
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+func (s *memoryStore) close() {
+	close(s.stopChan)
+}
+
+// sqliteStore partage les seaux entre processus via la table _rate_limits.
+// Chaque prise de jeton est une seule instruction UPSERT, donc atomique.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(db *sql.DB) (*sqliteStore, error) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS _rate_limits (
+		key TEXT PRIMARY KEY,
+		tokens REAL NOT NULL,
+		updated_at REAL NOT NULL,
+		allowed INTEGER NOT NULL
+	);`
+
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create rate limits table: %w", err)
+	}
+
+	// Purger les seaux inactifs laissés par les exécutions précédentes
+	db.Exec("DELETE FROM _rate_limits WHERE updated_at < ?", unixSeconds(time.Now().Add(-idleBucketTTL)))
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) take(key string, capacity, rate float64, now time.Time) (float64, bool, error) {
+	// ?1 clé, ?2 capacité, ?3 débit (jetons/s), ?4 maintenant (secondes Unix)
+	const query = `
+	INSERT INTO _rate_limits (key, tokens, updated_at, allowed) VALUES (?1, ?2 - 1, ?4, 1)
+	ON CONFLICT(key) DO UPDATE SET
+		allowed = MIN(?2, tokens + MAX(0, ?4 - updated_at) * ?3) >= 1,
+		tokens = MIN(?2, tokens + MAX(0, ?4 - updated_at) * ?3)
+			- (MIN(?2, tokens + MAX(0, ?4 - updated_at) * ?3) >= 1),
+		updated_at = MAX(updated_at, ?4)
+	RETURNING tokens, allowed`
+
+	var tokens float64
+	var allowed bool
+	if err := s.db.QueryRow(query, key, capacity, rate, unixSeconds(now)).Scan(&tokens, &allowed); err != nil {
+		return 0, false, fmt.Errorf("failed to update rate limit bucket: %w", err)
+	}
+	return tokens, allowed, nil
+}
+
+func (s *sqliteStore) close() {}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
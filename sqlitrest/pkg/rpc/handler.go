@@ -1,14 +1,22 @@
 package rpc
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/cl-ment/sqlitrest/pkg/engine"
+	"github.com/cl-ment/sqlitrest/pkg/policies"
 )
 
 // RPCFunction représente une fonction SQL exposée comme RPC
@@ -18,21 +26,61 @@ type RPCFunction struct {
 	Parameters  []string `json:"parameters"`
 	Returns     string   `json:"returns"`
 	Method      string   `json:"method"` // GET, POST
+
+	// Fonctions définies en SQL
+	Params     []Param `json:"params,omitempty"`
+	Volatility string  `json:"volatility,omitempty"`
+	Source     string  `json:"source,omitempty"`
+	body       string
+	statements []sqlStatement
+
+	// Fonctions natives (Go)
+	handler NativeHandler
+}
+
+// ReaderPool fournit des connexions en lecture seule (db.Database)
+type ReaderPool interface {
+	Reader() *sql.DB
+}
+
+// callOptions regroupe les options d'un appel RPC
+type callOptions struct {
+	shape    *engine.QueryParameters // mise en forme du résultat (fonctions composables)
+	rollback bool                    // Prefer: tx=rollback
 }
 
 // RPCHandler gère les appels RPC aux fonctions SQL
 type RPCHandler struct {
-	db         *sql.DB
-	jwtManager *auth.JWTManager
-	functions  map[string]RPCFunction
+	db           *sql.DB
+	jwtManager   *auth.JWTManager
+	grants       *auth.GrantManager
+	policyEngine *policies.PolicyEngine
+	parser       *engine.QueryParser
+	builder      *engine.SQLBuilder
+	errors       *engine.ErrorHandler
+	builtins     map[string]RPCFunction // fonctions Go (démo et personnalisées)
+	functions    map[string]RPCFunction // builtins + fonctions SQL
+	readers      ReaderPool
+	config       Config
+	timeout      time.Duration
+	version      uint64 // incrémentée à chaque changement de fonctions
+	mutex        sync.RWMutex
+	stopChan     chan struct{}
 }
 
 // NewRPCHandler crée un nouveau handler RPC
-func NewRPCHandler(db *sql.DB, jwtManager *auth.JWTManager) *RPCHandler {
+func NewRPCHandler(db *sql.DB, jwtManager *auth.JWTManager, grants *auth.GrantManager, policyEngine *policies.PolicyEngine) *RPCHandler {
 	handler := &RPCHandler{
-		db:         db,
-		jwtManager: jwtManager,
-		functions:  make(map[string]RPCFunction),
+		db:           db,
+		jwtManager:   jwtManager,
+		grants:       grants,
+		policyEngine: policyEngine,
+		parser:       engine.NewQueryParser(),
+		builder:      engine.NewSQLBuilder(),
+		errors:       engine.NewErrorHandler(),
+		timeout:      defaultTimeout,
+		builtins:     make(map[string]RPCFunction),
+		functions:    make(map[string]RPCFunction),
 	}
 
 	// Charger les fonctions par défaut
@@ -41,166 +89,364 @@ func NewRPCHandler(db *sql.DB, jwtManager *auth.JWTManager) *RPCHandler {
 	return handler
 }
 
-// loadDefaultFunctions charge les fonctions RPC par défaut
+// loadDefaultFunctions enregistre les fonctions RPC de démonstration
 func (h *RPCHandler) loadDefaultFunctions() {
-	defaultFunctions := []RPCFunction{
+	defaultFunctions := []NativeFunction{
 		{
 			Name:        "hello",
 			Description: "Simple hello world function",
-			Parameters:  []string{"name"},
-			Returns:     "string",
+			Params:      []Param{{Name: "name", Type: "text", Default: "World"}},
+			Returns:     "object",
 			Method:      "POST",
+			Handler:     h.helloFunction,
 		},
 		{
 			Name:        "count_users",
 			Description: "Count total users",
-			Parameters:  []string{},
-			Returns:     "integer",
-			Method:      "GET",
+			Returns:     "object",
+			Volatility:  VolatilityStable,
+			Handler:     h.countUsersFunction,
 		},
 		{
 			Name:        "user_stats",
 			Description: "Get user statistics",
-			Parameters:  []string{"user_id"},
+			Params:      []Param{{Name: "user_id", Type: "text"}},
 			Returns:     "object",
 			Method:      "POST",
+			Volatility:  VolatilityStable,
+			Handler:     h.userStatsFunction,
 		},
 	}
 
 	for _, fn := range defaultFunctions {
-		h.functions[fn.Name] = fn
+		if err := h.Register(fn); err != nil {
+			log.Printf("Failed to register RPC function %s: %v", fn.Name, err)
+		}
+	}
+}
+
+// SetConfig configure les fichiers de fonctions SQL et la durée maximale des appels
+func (h *RPCHandler) SetConfig(config Config) {
+	h.config = config
+
+	h.timeout = defaultTimeout
+	if config.Timeout != "" {
+		if d, err := time.ParseDuration(config.Timeout); err == nil && d >= 0 {
+			h.timeout = d
+		} else {
+			log.Printf("Invalid rpc timeout %q, using %s", config.Timeout, h.timeout)
+		}
+	}
+}
+
+// SetReaderPool fournit les connexions de lecture utilisées par les fonctions
+// stable et immutable (le writer sinon)
+func (h *RPCHandler) SetReaderPool(readers ReaderPool) {
+	h.readers = readers
+}
+
+// LoadFunctions charge les fonctions SQL (table _rpc_functions puis fichiers,
+// qui l'emportent) et remplace atomiquement les fonctions exposées
+func (h *RPCHandler) LoadFunctions() error {
+	tableFunctions, err := h.loadTableFunctions()
+	if err != nil {
+		return err
+	}
+
+	var fileFunctions []*RPCFunction
+	if len(h.config.Files) > 0 {
+		if fileFunctions, err = loadFunctionFiles(h.config.Files); err != nil {
+			return err
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	functions := make(map[string]RPCFunction, len(h.builtins)+len(tableFunctions)+len(fileFunctions))
+	for name, fn := range h.builtins {
+		functions[name] = fn
+	}
+	for _, fn := range append(tableFunctions, fileFunctions...) {
+		if previous, exists := functions[fn.Name]; exists && previous.Source != fn.Source {
+			log.Printf("RPC function %s from %s overrides %s", fn.Name, fn.Source, sourceName(previous))
+		}
+		functions[fn.Name] = *fn
+	}
+	h.functions = functions
+	h.version++
+
+	log.Printf("Loaded %d SQL RPC functions", len(tableFunctions)+len(fileFunctions))
+	return nil
+}
+
+// StartWatching démarre le rechargement à chaud des fonctions SQL si configuré
+func (h *RPCHandler) StartWatching() {
+	if !h.config.Watch || h.stopChan != nil {
+		return
+	}
+
+	interval := defaultWatchInterval
+	if h.config.WatchInterval != "" {
+		if d, err := time.ParseDuration(h.config.WatchInterval); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("Invalid rpc watch_interval %q, using %s", h.config.WatchInterval, interval)
+		}
+	}
+
+	fingerprint, _ := h.fingerprintSources()
+	h.stopChan = make(chan struct{})
+	go h.watchSources(fingerprint, interval, h.stopChan)
+}
+
+// StopWatching arrête le rechargement à chaud
+func (h *RPCHandler) StopWatching() {
+	if h.stopChan != nil {
+		close(h.stopChan)
+		h.stopChan = nil
+	}
+}
+
+func sourceName(fn RPCFunction) string {
+	if fn.Source == "" {
+		return "built-in"
 	}
+	return fn.Source
 }
 
-// HandleRPC gère les requêtes RPC
+// HandleRPC gère les requêtes RPC et répond en JSON
 func (h *RPCHandler) HandleRPC(w http.ResponseWriter, req *http.Request) {
+	result, ok := h.Invoke(w, req)
+	if !ok {
+		return
+	}
+
+	if table, isTable := result.(*engine.QueryResult); isTable {
+		result = table.Rows
+		if table.Rows == nil {
+			result = []map[string]interface{}{}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// Invoke exécute la fonction RPC demandée et retourne son résultat, sans l'écrire.
+// Les fonctions retournant une table produisent un *engine.QueryResult. En cas
+// d'erreur, la réponse est déjà écrite et ok vaut false.
+func (h *RPCHandler) Invoke(w http.ResponseWriter, req *http.Request) (result interface{}, ok bool) {
 	// Extraire le nom de la fonction depuis l'URL
 	functionName := extractFunctionName(req.URL.Path)
 	if functionName == "" {
-		http.Error(w, `{"error":"Function name required"}`, http.StatusBadRequest)
-		return
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeFunctionNotFound, "Function name required"))
+		return nil, false
 	}
 
 	// Vérifier si la fonction existe
+	h.mutex.RLock()
 	function, exists := h.functions[functionName]
+	h.mutex.RUnlock()
 	if !exists {
-		http.Error(w, fmt.Sprintf(`{"error":"Function %s not found"}`, functionName), http.StatusNotFound)
-		return
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeFunctionNotFound, fmt.Sprintf("Could not find the function %s", functionName)))
+		return nil, false
 	}
 
 	// Vérifier la méthode HTTP
 	if req.Method != function.Method {
-		http.Error(w, fmt.Sprintf(`{"error":"Method %s not allowed, use %s"}`, req.Method, function.Method), http.StatusMethodNotAllowed)
-		return
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeInvalidMethod, fmt.Sprintf("Cannot use the %s method on RPC", req.Method),
+			fmt.Sprintf("Function %s must be called with %s", functionName, function.Method)))
+		return nil, false
 	}
 
 	// Authentifier la requête
 	authCtx, err := h.jwtManager.AuthenticateRequest(req)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"Authentication failed: %s"}`, err.Error()), http.StatusUnauthorized)
-		return
+		engine.WriteError(w, h.errors.AuthError(err.Error()))
+		return nil, false
+	}
+
+	// Vérifier le droit d'exécution
+	if !h.grants.Check(authCtx, auth.PrivilegeExecute, functionName) {
+		engine.WriteError(w, h.errors.PermissionError(string(auth.PrivilegeExecute), functionName, authCtx.Role))
+		return nil, false
 	}
 
-	// Parser les paramètres
-	var params map[string]interface{}
+	// Parser les paramètres ; la query string porte aussi select, filtres,
+	// order et limit à appliquer aux fonctions retournant une table
+	params, shaping := splitQuery(function, req.URL.Query(), req.Method == "GET")
 	if req.Method == "POST" {
 		if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Invalid JSON: %s"}`, err.Error()), http.StatusBadRequest)
-			return
+			engine.WriteError(w, h.errors.BodyError(err))
+			return nil, false
+		}
+	}
+
+	var shape *engine.QueryParameters
+	if len(shaping) > 0 {
+		if !function.composable() {
+			engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeQueryString,
+				fmt.Sprintf("Function %s does not return a table, select and filters are not supported", functionName)))
+			return nil, false
+		}
+		if shape, err = h.parser.ParseQuery(req.URL.Path, shaping); err != nil {
+			engine.WriteError(w, h.errors.QueryStringError(err))
+			return nil, false
+		}
+	}
+
+	// Exécuter la fonction, interrompue si elle dépasse le délai ou si le client abandonne
+	ctx := req.Context()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	rollback := preferRollback(req.Header.Get("Prefer"))
+	result, err = h.executeFunction(ctx, function, params, callOptions{shape: shape, rollback: rollback}, authCtx)
+	var paramErr *ParamError
+	switch {
+	case errors.As(err, &paramErr):
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeInvalidBody, paramErr.Message))
+		return nil, false
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		engine.WriteError(w, engine.NewAPIError(engine.ErrorTypeTimeout, "Canceling statement due to statement timeout",
+			fmt.Sprintf("Function %s timed out after %s", functionName, h.timeout)))
+		return nil, false
+	case err != nil:
+		engine.WriteError(w, h.errors.DatabaseError("Function execution", err))
+		return nil, false
+	}
+
+	if rollback {
+		w.Header().Set("Preference-Applied", "tx=rollback")
+	}
+	return result, true
+}
+
+// preferRollback indique si l'en-tête Prefer demande l'annulation de la transaction
+func preferRollback(prefer string) bool {
+	for _, preference := range strings.Split(prefer, ",") {
+		if strings.EqualFold(strings.TrimSpace(preference), "tx=rollback") {
+			return true
 		}
-	} else {
-		// Pour GET, parser les query params
-		params = make(map[string]interface{})
-		for key, values := range req.URL.Query() {
-			if len(values) > 0 {
-				params[key] = values[0]
-			}
+	}
+	return false
+}
+
+// runInTx exécute body dans une transaction : sur une connexion de lecture pour les
+// fonctions stable et immutable, sur le writer pour les volatiles. La transaction
+// est annulée si body échoue ou si rollback est demandé.
+func (h *RPCHandler) runInTx(ctx context.Context, fn RPCFunction, rollback bool, body func(tx *sql.Tx) (interface{}, error)) (interface{}, error) {
+	pool := h.db
+	readOnly := fn.Volatility == VolatilityStable || fn.Volatility == VolatilityImmutable
+	if readOnly && h.readers != nil {
+		if reader := h.readers.Reader(); reader != nil {
+			pool = reader
 		}
 	}
 
-	// Exécuter la fonction
-	result, err := h.executeFunction(function, params, authCtx)
+	tx, err := pool.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"Function execution failed: %s"}`, err.Error()), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Retourner le résultat
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(result)
+	result, err := body(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if rollback {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return result, nil
+}
+
+// splitQuery sépare les arguments d'un appel GET des paramètres de mise en forme
+// du résultat. Seules les fonctions composables acceptent ces derniers : pour les
+// autres, tout paramètre de GET est un argument (les inconnus sont refusés).
+func splitQuery(fn RPCFunction, query url.Values, isGet bool) (map[string]interface{}, url.Values) {
+	params := make(map[string]interface{})
+	shaping := url.Values{}
+
+	declared := make(map[string]bool)
+	for _, name := range fn.Parameters {
+		declared[name] = true
+	}
+
+	for key, values := range query {
+		if len(values) == 0 {
+			continue
+		}
+		if isGet && (declared[key] || !fn.composable()) {
+			params[key] = values[0]
+			continue
+		}
+		shaping[key] = values
+	}
+
+	return params, shaping
 }
 
 // executeFunction exécute une fonction RPC spécifique
-func (h *RPCHandler) executeFunction(function RPCFunction, params map[string]interface{}, authCtx *auth.AuthContext) (interface{}, error) {
-	switch function.Name {
-	case "hello":
-		return h.helloFunction(params)
-	case "count_users":
-		return h.countUsersFunction(params, authCtx)
-	case "user_stats":
-		return h.userStatsFunction(params, authCtx)
+func (h *RPCHandler) executeFunction(ctx context.Context, function RPCFunction, params map[string]interface{}, opts callOptions, authCtx *auth.AuthContext) (interface{}, error) {
+	switch {
+	case function.statements != nil:
+		return h.executeSQLFunction(ctx, function, params, opts, authCtx)
+	case function.handler != nil:
+		return h.executeNativeFunction(ctx, function, params, opts, authCtx)
 	default:
-		return nil, fmt.Errorf("unknown function: %s", function.Name)
+		return nil, fmt.Errorf("function %s has no implementation", function.Name)
 	}
 }
 
 // helloFunction - fonction de démonstration
-func (h *RPCHandler) helloFunction(params map[string]interface{}) (interface{}, error) {
-	name, ok := params["name"].(string)
-	if !ok {
-		name = "World"
-	}
-
+func (h *RPCHandler) helloFunction(call *Call) (interface{}, error) {
 	return map[string]interface{}{
-		"message":   fmt.Sprintf("Hello, %s!", name),
+		"message":   fmt.Sprintf("Hello, %s!", call.String("name")),
 		"timestamp": "2025-11-29T23:00:00Z",
 	}, nil
 }
 
-// countUsersFunction - compte les utilisateurs
-func (h *RPCHandler) countUsersFunction(params map[string]interface{}, authCtx *auth.AuthContext) (interface{}, error) {
+// countUsersFunction - compte les utilisateurs visibles selon les politiques
+func (h *RPCHandler) countUsersFunction(call *Call) (interface{}, error) {
 	var count int64
 
-	// Appliquer les politiques de sécurité
-	var query string
-	var args []interface{}
-
-	if authCtx.Authenticated && authCtx.Role == "admin" {
-		query = "SELECT COUNT(*) FROM users"
-		args = nil
-	} else if authCtx.Authenticated {
-		query = "SELECT COUNT(*) FROM users WHERE id = ?"
-		args = []interface{}{authCtx.UserID}
-	} else {
-		query = "SELECT COUNT(*) FROM users WHERE is_public = TRUE"
-		args = nil
+	query, err := call.SecureQuery("SELECT COUNT(*) FROM users")
+	if err != nil {
+		return nil, err
 	}
 
-	err := h.db.QueryRow(query, args...).Scan(&count)
+	err = call.Tx.QueryRowContext(call.Context, query).Scan(&count)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count users: %w", err)
 	}
 
 	return map[string]interface{}{
 		"count": count,
-		"role":  authCtx.Role,
+		"role":  call.Auth.Role,
 	}, nil
 }
 
 // userStatsFunction - statistiques utilisateur
-func (h *RPCHandler) userStatsFunction(params map[string]interface{}, authCtx *auth.AuthContext) (interface{}, error) {
-	userID, ok := params["user_id"].(string)
-	if !ok {
-		if authCtx.Authenticated {
-			userID = authCtx.UserID
-		} else {
-			return nil, fmt.Errorf("user_id parameter required")
+func (h *RPCHandler) userStatsFunction(call *Call) (interface{}, error) {
+	userID := call.String("user_id")
+	if userID == "" {
+		if !call.Auth.Authenticated {
+			return nil, &ParamError{Message: "missing parameter user_id"}
 		}
+		userID = call.Auth.UserID
 	}
 
 	// Vérifier les permissions
-	if authCtx.UserID != userID && authCtx.Role != "admin" {
+	if call.Auth.UserID != userID && call.Auth.Role != "admin" {
 		return nil, fmt.Errorf("access denied")
 	}
 
@@ -212,25 +458,52 @@ func (h *RPCHandler) userStatsFunction(params map[string]interface{}, authCtx *a
 	}
 
 	// Récupérer les infos utilisateur
-	err := h.db.QueryRow("SELECT id, name, email FROM users WHERE id = ?", userID).Scan(&stats.ID, &stats.Name, &stats.Email)
+	userQuery, err := call.SecureQuery("SELECT id, name, email FROM users WHERE id = ?")
+	if err != nil {
+		return nil, err
+	}
+	err = call.Tx.QueryRowContext(call.Context, userQuery, userID).Scan(&stats.ID, &stats.Name, &stats.Email)
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
 	// Compter les posts (si la table existe)
 	var postCount int64
-	h.db.QueryRow("SELECT COUNT(*) FROM posts WHERE author_id = ?", userID).Scan(&postCount)
+	postQuery, err := call.SecureQuery("SELECT COUNT(*) FROM posts WHERE author_id = ?")
+	if err != nil {
+		return nil, err
+	}
+	call.Tx.QueryRowContext(call.Context, postQuery, userID).Scan(&postCount)
 	stats.PostCount = postCount
 
 	return stats, nil
 }
 
+// secureQuery applique les politiques aux tables lues et à la table modifiée par une requête
+func (h *RPCHandler) secureQuery(query string, authCtx *auth.AuthContext) (string, error) {
+	if h.policyEngine == nil {
+		return query, nil
+	}
+
+	secured, err := h.policyEngine.SecureQuery(query, authCtx)
+	if err != nil {
+		return "", fmt.Errorf("policy application failed: %w", err)
+	}
+	return secured, nil
+}
+
 // ListFunctions retourne la liste des fonctions disponibles
 func (h *RPCHandler) ListFunctions() []RPCFunction {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
 	var functions []RPCFunction
 	for _, fn := range h.functions {
 		functions = append(functions, fn)
 	}
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].Name < functions[j].Name
+	})
 	return functions
 }
 
@@ -244,8 +517,22 @@ func extractFunctionName(path string) string {
 	return ""
 }
 
-// RegisterCustomFunction enregistre une fonction personnalisée
+// RegisterCustomFunction déclare une fonction sans implémentation.
+//
+// Deprecated: utiliser Register avec un NativeHandler.
 func (h *RPCHandler) RegisterCustomFunction(fn RPCFunction) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.builtins[fn.Name] = fn
 	h.functions[fn.Name] = fn
+	h.version++
 	log.Printf("Registered custom RPC function: %s", fn.Name)
 }
+
+// Version change à chaque chargement ou enregistrement de fonctions
+func (h *RPCHandler) Version() uint64 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.version
+}
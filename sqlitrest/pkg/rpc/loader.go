@@ -0,0 +1,231 @@
+package rpc
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 2 * time.Second
+	defaultTimeout       = 30 * time.Second
+)
+
+// Config contient la configuration des fonctions RPC (section [rpc])
+type Config struct {
+	Files         []string `toml:"files"`          // chemins ou motifs glob de fichiers .sql
+	Watch         bool     `toml:"watch"`          // recharger à chaud fichiers et table _rpc_functions
+	WatchInterval string   `toml:"watch_interval"` // durée entre deux vérifications, ex: "2s"
+	Timeout       string   `toml:"timeout"`        // durée maximale d'un appel (défaut: 30s, "0" pour aucune)
+}
+
+// resolveFunctionFiles développe les motifs glob en liste de fichiers triée
+func resolveFunctionFiles(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid function file pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("function file %s not found", pattern)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseFunctionFile parse un fichier .sql dont l'en-tête déclare la fonction :
+//
+//	-- name: posts_by_author
+//	-- description: Posts of an author
+//	-- params: author_id integer, since text = '2020-01-01'
+//	-- method: GET
+//	-- volatility: stable
+//	-- returns: table
+//	SELECT * FROM posts WHERE author_id = :author_id AND created_at >= :since;
+func parseFunctionFile(path string) (*RPCFunction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read function file %s: %w", path, err)
+	}
+
+	header := make(map[string]string)
+	lines := strings.Split(string(data), "\n")
+	bodyStart := len(lines)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "--") {
+			bodyStart = i
+			break
+		}
+		key, value, found := strings.Cut(strings.TrimSpace(strings.TrimPrefix(trimmed, "--")), ":")
+		if found {
+			key = strings.ToLower(strings.TrimSpace(key))
+			// "params" peut s'étendre sur plusieurs lignes d'en-tête
+			if existing, ok := header[key]; ok && key == "params" {
+				value = existing + "," + value
+			}
+			header[key] = strings.TrimSpace(value)
+		}
+	}
+
+	name := header["name"]
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	fn, err := newSQLFunction(name, header["description"], header["params"], header["method"],
+		header["volatility"], header["returns"], strings.Join(lines[bodyStart:], "\n"), path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fn, nil
+}
+
+// loadFunctionFiles charge toutes les fonctions des fichiers.
+// Un seul fichier invalide fait échouer l'ensemble du chargement.
+func loadFunctionFiles(patterns []string) ([]*RPCFunction, error) {
+	files, err := resolveFunctionFiles(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]string)
+	var functions []*RPCFunction
+	for _, path := range files {
+		fn, err := parseFunctionFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if previous, exists := seen[fn.Name]; exists {
+			return nil, fmt.Errorf("function %s defined in both %s and %s", fn.Name, previous, path)
+		}
+		seen[fn.Name] = path
+		functions = append(functions, fn)
+	}
+
+	return functions, nil
+}
+
+// loadTableFunctions charge les fonctions actives de la table _rpc_functions
+func (h *RPCHandler) loadTableFunctions() ([]*RPCFunction, error) {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS _rpc_functions (
+		name TEXT PRIMARY KEY,
+		description TEXT,
+		params TEXT NOT NULL DEFAULT '',
+		method TEXT,
+		volatility TEXT NOT NULL DEFAULT 'volatile' CHECK (volatility IN ('immutable', 'stable', 'volatile')),
+		returns TEXT NOT NULL DEFAULT 'table' CHECK (returns IN ('table', 'row', 'scalar', 'void')),
+		body TEXT NOT NULL,
+		enabled BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := h.db.Exec(createTableSQL); err != nil {
+		return nil, fmt.Errorf("failed to create rpc functions table: %w", err)
+	}
+
+	rows, err := h.db.Query(`SELECT name, COALESCE(description, ''), params, COALESCE(method, ''), volatility, returns, body
+		FROM _rpc_functions WHERE enabled = TRUE ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rpc functions: %w", err)
+	}
+	defer rows.Close()
+
+	var functions []*RPCFunction
+	for rows.Next() {
+		var name, description, params, method, volatility, returns, body string
+		if err := rows.Scan(&name, &description, &params, &method, &volatility, &returns, &body); err != nil {
+			return nil, fmt.Errorf("failed to scan rpc function: %w", err)
+		}
+
+		fn, err := newSQLFunction(name, description, params, method, volatility, returns, body, "_rpc_functions")
+		if err != nil {
+			return nil, err
+		}
+		functions = append(functions, fn)
+	}
+
+	return functions, rows.Err()
+}
+
+// fingerprintSources calcule une empreinte des fichiers et de la table _rpc_functions
+func (h *RPCHandler) fingerprintSources() (string, error) {
+	hash := sha256.New()
+
+	files, err := resolveFunctionFiles(h.config.Files)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(path))
+		hash.Write([]byte{0})
+		hash.Write(data)
+		hash.Write([]byte{0})
+	}
+
+	var tableState string
+	err = h.db.QueryRow(`SELECT COALESCE(group_concat(name || COALESCE(description, '') || params || COALESCE(method, '') || volatility || returns || body || enabled, char(0)), '')
+		FROM (SELECT * FROM _rpc_functions ORDER BY name)`).Scan(&tableState)
+	if err != nil {
+		return "", err
+	}
+	hash.Write([]byte(tableState))
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// watchSources recharge les fonctions quand les fichiers ou la table changent
+func (h *RPCHandler) watchSources(last string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			current, err := h.fingerprintSources()
+			if err != nil {
+				if current != last {
+					log.Printf("RPC function sources unreadable, keeping previous functions: %v", err)
+					last = current
+				}
+				continue
+			}
+			if current == last {
+				continue
+			}
+			last = current
+
+			if err := h.LoadFunctions(); err != nil {
+				log.Printf("Rejected RPC function reload, keeping previous functions: %v", err)
+				continue
+			}
+			log.Printf("RPC functions reloaded")
+		}
+	}
+}
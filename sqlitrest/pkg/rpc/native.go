@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+)
+
+// NativeHandler est le code Go d'une fonction RPC native. Le résultat est
+// encodé en JSON ; une *ParamError produit une réponse 400.
+type NativeHandler func(call *Call) (interface{}, error)
+
+// NativeFunction décrit une fonction RPC implémentée en Go
+type NativeFunction struct {
+	Name        string
+	Description string
+	Params      []Param // paramètres acceptés, décodés et validés avant l'appel
+	Method      string  // défaut: POST, GET si la fonction n'est pas volatile
+	Volatility  string  // défaut: volatile
+	Returns     string  // table, row, scalar, void ou description libre (défaut: row)
+	Handler     NativeHandler
+}
+
+// Call est le contexte d'exécution d'une fonction native
+type Call struct {
+	Context context.Context
+	Auth    *auth.AuthContext
+	Tx      *sql.Tx                // validée si le handler réussit, annulée sinon ou avec Prefer: tx=rollback
+	Params  map[string]interface{} // valeurs typées (int64, float64, bool, string, JSON en string)
+
+	handler *RPCHandler
+}
+
+// Has indique si un paramètre a une valeur non nulle
+func (c *Call) Has(name string) bool {
+	return c.Params[name] != nil
+}
+
+// String retourne un paramètre text (chaîne vide si absent)
+func (c *Call) String(name string) string {
+	s, _ := c.Params[name].(string)
+	return s
+}
+
+// Int retourne un paramètre integer (0 si absent)
+func (c *Call) Int(name string) int64 {
+	i, _ := c.Params[name].(int64)
+	return i
+}
+
+// Float retourne un paramètre real (0 si absent)
+func (c *Call) Float(name string) float64 {
+	f, _ := c.Params[name].(float64)
+	return f
+}
+
+// Bool retourne un paramètre boolean (false si absent)
+func (c *Call) Bool(name string) bool {
+	b, _ := c.Params[name].(bool)
+	return b
+}
+
+// JSON décode un paramètre json dans dest
+func (c *Call) JSON(name string, dest interface{}) error {
+	s, ok := c.Params[name].(string)
+	if !ok {
+		return fmt.Errorf("parameter %s is not set", name)
+	}
+	if err := json.Unmarshal([]byte(s), dest); err != nil {
+		return &ParamError{Message: fmt.Sprintf("parameter %s: %v", name, err)}
+	}
+	return nil
+}
+
+// SecureQuery applique les politiques de l'appelant aux tables lues par la requête
+func (c *Call) SecureQuery(query string) (string, error) {
+	return c.handler.secureQuery(query, c.Auth)
+}
+
+// Register enregistre une fonction RPC implémentée en Go. Elle apparaît dans
+// ListFunctions et dans la spécification OpenAPI comme les fonctions SQL.
+func (h *RPCHandler) Register(native NativeFunction) error {
+	fn := RPCFunction{
+		Name:        native.Name,
+		Description: native.Description,
+		Params:      append([]Param(nil), native.Params...),
+		Method:      normalizeMethod(native.Method, native.Volatility),
+		Volatility:  native.Volatility,
+		Returns:     native.Returns,
+		handler:     native.Handler,
+	}
+	if fn.Volatility == "" {
+		fn.Volatility = VolatilityVolatile
+	}
+	if fn.Returns == "" {
+		fn.Returns = ReturnsRow
+	}
+
+	if fn.Name == "" || !isIdentStart(fn.Name[0]) {
+		return fmt.Errorf("invalid function name %q", fn.Name)
+	}
+	if fn.handler == nil {
+		return fmt.Errorf("function %s has no handler", fn.Name)
+	}
+	switch fn.Volatility {
+	case VolatilityImmutable, VolatilityStable:
+	case VolatilityVolatile:
+		if fn.Method == "GET" {
+			return fmt.Errorf("function %s is volatile and cannot use GET", fn.Name)
+		}
+	default:
+		return fmt.Errorf("function %s: invalid volatility %q", fn.Name, fn.Volatility)
+	}
+	if err := validateParams(fn.Params); err != nil {
+		return fmt.Errorf("function %s: %w", fn.Name, err)
+	}
+
+	fn.Parameters = []string{}
+	for _, p := range fn.Params {
+		fn.Parameters = append(fn.Parameters, p.Name)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.builtins[fn.Name] = fn
+	if existing, exists := h.functions[fn.Name]; exists && existing.statements != nil {
+		// Les fonctions SQL l'emportent, comme lors d'un rechargement
+		log.Printf("RPC function %s from %s overrides native function", fn.Name, existing.Source)
+		return nil
+	}
+	h.functions[fn.Name] = fn
+	h.version++
+	return nil
+}
+
+// validateParams vérifie les types, l'unicité et les valeurs par défaut des paramètres
+func validateParams(params []Param) error {
+	declared := make(map[string]bool, len(params))
+	for i, p := range params {
+		if p.Name == "" || !isIdentStart(p.Name[0]) {
+			return fmt.Errorf("invalid parameter name %q", p.Name)
+		}
+		if declared[p.Name] {
+			return fmt.Errorf("duplicate parameter %s", p.Name)
+		}
+		declared[p.Name] = true
+
+		switch p.Type {
+		case "text", "integer", "real", "boolean", "json":
+		default:
+			return fmt.Errorf("parameter %s: unsupported type %q", p.Name, p.Type)
+		}
+
+		if p.Default != nil {
+			coerced, err := coerceParam(p, p.Default)
+			if err != nil {
+				return fmt.Errorf("parameter %s: invalid default: %w", p.Name, err)
+			}
+			params[i].Default = coerced
+		}
+	}
+	return nil
+}
+
+// normalizeMethod retourne la méthode HTTP d'une fonction : POST par défaut,
+// GET pour les fonctions en lecture seule
+func normalizeMethod(method, volatility string) string {
+	if method != "" {
+		return strings.ToUpper(method)
+	}
+	if volatility == VolatilityImmutable || volatility == VolatilityStable {
+		return "GET"
+	}
+	return "POST"
+}
+
+// executeNativeFunction valide les paramètres puis appelle le handler dans une transaction
+func (h *RPCHandler) executeNativeFunction(ctx context.Context, fn RPCFunction, values map[string]interface{}, opts callOptions, authCtx *auth.AuthContext) (interface{}, error) {
+	bound, err := bindParams(fn.Params, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.runInTx(ctx, fn, opts.rollback, func(tx *sql.Tx) (interface{}, error) {
+		return fn.handler(&Call{
+			Context: ctx,
+			Auth:    authCtx,
+			Tx:      tx,
+			Params:  bound,
+			handler: h,
+		})
+	})
+}
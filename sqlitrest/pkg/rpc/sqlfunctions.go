@@ -0,0 +1,583 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cl-ment/sqlitrest/pkg/auth"
+	"github.com/cl-ment/sqlitrest/pkg/engine"
+	"github.com/cl-ment/sqlitrest/pkg/policies"
+)
+
+// Volatilité d'une fonction (sémantique PostgreSQL)
+const (
+	VolatilityImmutable = "immutable" // même résultat pour les mêmes paramètres, lecture seule
+	VolatilityStable    = "stable"    // lecture seule
+	VolatilityVolatile  = "volatile"  // peut modifier la base (défaut)
+)
+
+// Formes de retour d'une fonction SQL
+const (
+	ReturnsTable  = "table"  // tableau d'objets (défaut)
+	ReturnsRow    = "row"    // premier objet ou null
+	ReturnsScalar = "scalar" // première colonne de la première ligne
+	ReturnsVoid   = "void"   // aucun résultat
+)
+
+// Param décrit un paramètre typé de fonction RPC
+type Param struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"` // text, integer, real, boolean, json
+	Required bool        `json:"required"`
+	Default  interface{} `json:"default,omitempty"`
+}
+
+// ParamError signale des paramètres d'appel invalides (réponse 400)
+type ParamError struct {
+	Message string
+}
+
+func (e *ParamError) Error() string {
+	return e.Message
+}
+
+// parseParams parse une déclaration de paramètres :
+// "author_id integer, since text = '2020-01-01', tags json?"
+func parseParams(declaration string) ([]Param, error) {
+	var params []Param
+	for _, item := range splitTopLevel(declaration, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		param := Param{Required: true}
+		spec, defaultValue, hasDefault := strings.Cut(item, "=")
+		fields := strings.Fields(spec)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid parameter %q, expected \"name type\"", item)
+		}
+		param.Name = fields[0]
+		param.Type = strings.ToLower(fields[1])
+		if strings.HasSuffix(param.Type, "?") {
+			param.Type = strings.TrimSuffix(param.Type, "?")
+			param.Required = false
+		}
+
+		switch param.Type {
+		case "text", "integer", "real", "boolean", "json":
+		default:
+			return nil, fmt.Errorf("parameter %s: unsupported type %q", param.Name, param.Type)
+		}
+
+		if hasDefault {
+			value, err := parseDefault(strings.TrimSpace(defaultValue))
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", param.Name, err)
+			}
+			coerced, err := coerceParam(param, value)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: invalid default: %w", param.Name, err)
+			}
+			param.Default = coerced
+			param.Required = false
+		}
+
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// parseDefault interprète une valeur par défaut : 'texte', nombre, true/false, null ou JSON
+func parseDefault(value string) (interface{}, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if strings.EqualFold(value, "null") {
+		return nil, nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return nil, fmt.Errorf("invalid default value %s", value)
+	}
+	return decoded, nil
+}
+
+// coerceParam convertit une valeur reçue (JSON ou query string) vers le type déclaré
+func coerceParam(param Param, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch param.Type {
+	case "text":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64, int64, int, bool:
+			return fmt.Sprint(v), nil
+		}
+
+	case "integer":
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case int:
+			return int64(v), nil
+		case float64:
+			if v == float64(int64(v)) {
+				return int64(v), nil
+			}
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return i, nil
+			}
+		}
+
+	case "real":
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case int:
+			return float64(v), nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, nil
+			}
+		}
+
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+
+	case "json":
+		// Les chaînes venant d'une query string sont supposées déjà en JSON
+		if s, ok := value.(string); ok && json.Valid([]byte(s)) {
+			return s, nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+
+	return nil, fmt.Errorf("expected %s, got %v", param.Type, value)
+}
+
+// bindParams valide les paramètres d'appel et retourne les valeurs typées
+func bindParams(declared []Param, values map[string]interface{}) (map[string]interface{}, error) {
+	bound := make(map[string]interface{}, len(declared))
+	known := make(map[string]bool, len(declared))
+	var problems []string
+
+	for _, param := range declared {
+		known[param.Name] = true
+
+		value, present := values[param.Name]
+		if !present {
+			if param.Required {
+				problems = append(problems, fmt.Sprintf("missing parameter %s", param.Name))
+				continue
+			}
+			bound[param.Name] = param.Default
+			continue
+		}
+
+		coerced, err := coerceParam(param, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("parameter %s: %v", param.Name, err))
+			continue
+		}
+		if coerced == nil && param.Required {
+			problems = append(problems, fmt.Sprintf("parameter %s cannot be null", param.Name))
+			continue
+		}
+		bound[param.Name] = coerced
+	}
+
+	for name := range values {
+		if !known[name] {
+			problems = append(problems, fmt.Sprintf("unknown parameter %s", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ParamError{Message: strings.Join(problems, "; ")}
+	}
+	return bound, nil
+}
+
+// sqlStatement est une instruction d'un corps de fonction et ses paramètres nommés
+type sqlStatement struct {
+	sql    string
+	params []string
+	read   bool
+}
+
+// splitStatements découpe un corps SQL en instructions (";" hors chaînes et commentaires)
+// et relève les paramètres nommés (:nom, @nom, $nom) de chacune
+func splitStatements(body string) []sqlStatement {
+	var statements []sqlStatement
+	var params []string
+	seen := map[string]bool{}
+	start := 0
+
+	flush := func(end int) {
+		text := strings.TrimSpace(body[start:end])
+		if stripComments(text) != "" {
+			statements = append(statements, sqlStatement{sql: text, params: params, read: isReadOnly(text)})
+		}
+		params, seen = nil, map[string]bool{}
+	}
+
+	scanSQL(body, func(paramStart, paramEnd int) {
+		if name := body[paramStart+1 : paramEnd]; !seen[name] {
+			seen[name] = true
+			params = append(params, name)
+		}
+	}, func(i int) {
+		flush(i)
+		start = i + 1
+	})
+	flush(len(body))
+
+	return statements
+}
+
+// scanSQL parcourt du SQL en ignorant chaînes, identifiants entre guillemets et
+// commentaires. onParam reçoit la position d'un paramètre nommé (préfixe compris),
+// onSeparator celle de chaque ";".
+func scanSQL(text string, onParam func(start, end int), onSeparator func(i int)) {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(text, i, c)
+		case c == '[':
+			if end := strings.IndexByte(text[i:], ']'); end > 0 {
+				i += end
+			}
+		case c == '-' && i+1 < len(text) && text[i+1] == '-':
+			if end := strings.IndexByte(text[i:], '\n'); end > 0 {
+				i += end
+			} else {
+				i = len(text)
+			}
+		case c == '/' && i+1 < len(text) && text[i+1] == '*':
+			if end := strings.Index(text[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(text)
+			}
+		case (c == ':' || c == '@' || c == '$') && i+1 < len(text) && isIdentStart(text[i+1]):
+			j := i + 1
+			for j < len(text) && isIdentPart(text[j]) {
+				j++
+			}
+			onParam(i, j)
+			i = j - 1
+		case c == ';':
+			if onSeparator != nil {
+				onSeparator(i)
+			}
+		}
+	}
+}
+
+// positionalSQL remplace les paramètres nommés par ?1, ?2... dans l'ordre de
+// names, pour composer l'instruction avec une requête à paramètres anonymes
+func positionalSQL(text string, names []string) string {
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i + 1
+	}
+
+	var out strings.Builder
+	last := 0
+	scanSQL(text, func(start, end int) {
+		if n, ok := index[text[start+1:end]]; ok {
+			out.WriteString(text[last:start])
+			fmt.Fprintf(&out, "?%d", n)
+			last = end
+		}
+	}, nil)
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// skipQuoted retourne la position du guillemet fermant (les guillemets doublés sont échappés)
+func skipQuoted(s string, i int, quote byte) int {
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == quote {
+			if j+1 < len(s) && s[j+1] == quote {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(s)
+}
+
+// splitTopLevel découpe une liste sur un séparateur, hors chaînes et parenthèses
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			i = skipQuoted(s, i, '\'')
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// stripComments retire les lignes de commentaires pour savoir si une instruction est vide
+func stripComments(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			lines = append(lines, trimmed)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isReadOnly indique si une instruction ne fait que lire ; le découpage en tokens
+// ignore les mots-clés des chaînes et des commentaires
+func isReadOnly(statement string) bool {
+	return policies.StatementAction(statement) == "SELECT"
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// validateSQLFunction vérifie la cohérence d'une fonction SQL
+func validateSQLFunction(fn *RPCFunction) error {
+	if fn.Name == "" || !isIdentStart(fn.Name[0]) {
+		return fmt.Errorf("invalid function name %q", fn.Name)
+	}
+	if len(fn.statements) == 0 {
+		return fmt.Errorf("function %s has no SQL statement", fn.Name)
+	}
+
+	switch fn.Volatility {
+	case VolatilityImmutable, VolatilityStable:
+		for _, stmt := range fn.statements {
+			if !stmt.read {
+				return fmt.Errorf("function %s is %s but contains a write statement", fn.Name, fn.Volatility)
+			}
+		}
+	case VolatilityVolatile:
+		if fn.Method == "GET" {
+			return fmt.Errorf("function %s is volatile and cannot use GET", fn.Name)
+		}
+	default:
+		return fmt.Errorf("function %s: invalid volatility %q", fn.Name, fn.Volatility)
+	}
+
+	switch fn.Returns {
+	case ReturnsTable, ReturnsRow, ReturnsScalar, ReturnsVoid:
+	default:
+		return fmt.Errorf("function %s: invalid return shape %q", fn.Name, fn.Returns)
+	}
+
+	if err := validateParams(fn.Params); err != nil {
+		return fmt.Errorf("function %s: %w", fn.Name, err)
+	}
+	declared := make(map[string]bool, len(fn.Params))
+	for _, p := range fn.Params {
+		declared[p.Name] = true
+	}
+	for _, stmt := range fn.statements {
+		for _, name := range stmt.params {
+			if !declared[name] {
+				return fmt.Errorf("function %s: undeclared parameter %s", fn.Name, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// newSQLFunction construit une fonction RPC à partir de sa définition
+func newSQLFunction(name, description, params, method, volatility, returns, body, source string) (*RPCFunction, error) {
+	fn := &RPCFunction{
+		Name:        strings.TrimSpace(name),
+		Description: strings.TrimSpace(description),
+		Volatility:  strings.ToLower(strings.TrimSpace(volatility)),
+		Returns:     strings.ToLower(strings.TrimSpace(returns)),
+		Source:      source,
+		body:        body,
+		statements:  splitStatements(body),
+	}
+
+	var err error
+	if fn.Params, err = parseParams(params); err != nil {
+		return nil, fmt.Errorf("function %s: %w", fn.Name, err)
+	}
+	fn.Parameters = []string{}
+	for _, p := range fn.Params {
+		fn.Parameters = append(fn.Parameters, p.Name)
+	}
+
+	if fn.Volatility == "" {
+		fn.Volatility = VolatilityVolatile
+	}
+	fn.Method = normalizeMethod(strings.TrimSpace(method), fn.Volatility)
+	if fn.Returns == "" {
+		fn.Returns = ReturnsTable
+	}
+
+	if err := validateSQLFunction(fn); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// executeSQLFunction exécute le corps d'une fonction SQL dans une transaction.
+// Chaque instruction reçoit les politiques des tables lues et modifiées ; le
+// résultat est celui de la dernière.
+// Si opts.shape est fourni, la dernière instruction devient une sous-requête à laquelle
+// s'appliquent select, filtres, tri et pagination.
+func (h *RPCHandler) executeSQLFunction(ctx context.Context, fn RPCFunction, values map[string]interface{}, opts callOptions, authCtx *auth.AuthContext) (interface{}, error) {
+	bound, err := bindParams(fn.Params, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.runInTx(ctx, fn, opts.rollback, func(tx *sql.Tx) (interface{}, error) {
+		var result interface{}
+		for i, stmt := range fn.statements {
+			query, err := h.secureQuery(stmt.sql, authCtx)
+			if err != nil {
+				return nil, err
+			}
+
+			args := make([]interface{}, 0, len(stmt.params))
+			for _, name := range stmt.params {
+				args = append(args, sql.Named(name, bound[name]))
+			}
+
+			if i < len(fn.statements)-1 || fn.Returns == ReturnsVoid {
+				if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+					return nil, fmt.Errorf("statement %d failed: %w", i+1, err)
+				}
+				continue
+			}
+
+			if opts.shape != nil {
+				// Les paramètres nommés deviennent ?1..?n pour précéder les
+				// paramètres anonymes des filtres
+				args = args[:0]
+				for _, name := range stmt.params {
+					args = append(args, bound[name])
+				}
+				wrapped, shapeArgs, err := h.builder.BuildSelectFrom(positionalSQL(query, stmt.params), opts.shape)
+				if err != nil {
+					return nil, &ParamError{Message: err.Error()}
+				}
+				query = wrapped
+				args = append(args, shapeArgs...)
+			}
+
+			rows, err := tx.QueryContext(ctx, query, args...)
+			if err != nil {
+				return nil, fmt.Errorf("statement %d failed: %w", i+1, err)
+			}
+			if result, err = shapeRows(rows, fn.Returns); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	})
+}
+
+// composable indique si le résultat d'une fonction peut être filtré comme une
+// table : fonction SQL retournant une table dont la dernière instruction est une lecture
+func (fn RPCFunction) composable() bool {
+	if fn.Returns != ReturnsTable || len(fn.statements) == 0 {
+		return false
+	}
+	return fn.statements[len(fn.statements)-1].read
+}
+
+// shapeRows lit le résultat de la dernière instruction selon la forme de retour
+func shapeRows(rows *sql.Rows, returns string) (interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []map[string]interface{}{}
+	var scalar interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			record[column] = values[i]
+		}
+		if len(records) == 0 && len(columns) > 0 {
+			scalar = values[0]
+		}
+		records = append(records, record)
+
+		if returns != ReturnsTable {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch returns {
+	case ReturnsRow:
+		if len(records) == 0 {
+			return nil, nil
+		}
+		return records[0], nil
+	case ReturnsScalar:
+		return scalar, nil
+	}
+	return &engine.QueryResult{Columns: columns, Rows: records, Count: len(records)}, nil
+}